@@ -4,23 +4,66 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
+	"net"
+	"net/url"
+	"strings"
 )
 
 // ConfigData is the root configuration for the OTEL observability stack
 type ConfigData struct {
-	ServiceName           string      `json:"service_name"`
-	ServiceVersion        string      `json:"service_version"`
-	DeployEnv             string      `json:"deploy_env"`
-	Layers                *LayersOpts `json:"layers"`
-	Exporters             Exporters   `json:"exporters"`
-	SkipPaths             []string    `json:"skip_paths"`
-	MetricReportingPeriod *int        `json:"metric_reporting_period"`
-	TraceSampleRate       *float64    `json:"trace_sample_rate"`
+	ServiceName           string              `json:"service_name"`
+	ServiceVersion        string              `json:"service_version"`
+	DeployEnv             string              `json:"deploy_env"`
+	Layers                *LayersOpts         `json:"layers"`
+	Exporters             Exporters           `json:"exporters"`
+	SkipPaths             []string            `json:"skip_paths"`
+	MetricReportingPeriod *int                `json:"metric_reporting_period"`
+	TraceSampleRate       *float64            `json:"trace_sample_rate"`
+	Sampler               *SamplerOpts        `json:"sampler"`
+	RemoteSampling        *RemoteSamplingOpts `json:"remote_sampling"`
+	Resource              *ResourceOpts       `json:"resource"`
+	EnvPrecedence         string              `json:"env_precedence"`
+	TailSampling          *TailSamplingOpts   `json:"tail_sampling"`
+
+	// Propagators are the formats used to inject the context into the
+	// backend requests, and to extract it from the incoming ones. When
+	// not set, the OTEL_PROPAGATORS env var or the W3C trace context
+	// and baggage are used.
+	Propagators []string `json:"propagators"`
+
+	// sources records the fields set from environment variables
+	sources map[string]string
 }
 
 func (c *ConfigData) Validate() error {
-	return c.Exporters.Validate()
+	if err := c.Resource.Validate(); err != nil {
+		return err
+	}
+	if err := c.Sampler.Validate(); err != nil {
+		return err
+	}
+	if err := c.RemoteSampling.Validate(); err != nil {
+		return err
+	}
+	if err := c.TailSampling.Validate(); err != nil {
+		return err
+	}
+	if err := ValidatePropagators(c.Propagators); err != nil {
+		return err
+	}
+	if c.Layers != nil {
+		if err := c.Layers.Global.Validate(); err != nil {
+			return err
+		}
+	}
+	if c.Layers != nil && c.Layers.Backend != nil && c.Layers.Backend.Traces != nil {
+		if err := ValidatePropagators(c.Layers.Backend.Traces.Propagators); err != nil {
+			return err
+		}
+	}
+	return c.withSources(c.Exporters.Validate())
 }
 
 func (c *ConfigData) UnsetFieldsToDefaults() {
@@ -86,18 +129,39 @@ func (c *ConfigData) UnsetFieldsToDefaults() {
 	}
 }
 
+// GlobalBaggageAttributes returns the baggage members to promote to
+// attributes, set at the global layer, or nil when they are not set.
+func (c *ConfigData) GlobalBaggageAttributes() *BaggageAttributesOpts {
+	if c == nil || c.Layers == nil || c.Layers.Global == nil {
+		return nil
+	}
+	return c.Layers.Global.BaggageAttributes
+}
+
+// Exporters contains the configuration of all the exporters, that
+// must have a unique name among all types.
+//
+// Custom exporters are created by the factory registered for its
+// type (see the exporter package).
 type Exporters struct {
 	OTLP       []OTLPExporter       `json:"otlp"`
 	Prometheus []PrometheusExporter `json:"prometheus"`
+	File       []FileExporter       `json:"file"`
+	Zipkin     []ZipkinExporter     `json:"zipkin"`
+	StatsD     []StatsDExporter     `json:"statsd"`
+	Custom     []CustomExporter     `json:"custom"`
 }
 
 func (e *Exporters) Validate() error {
-	uniqueNames := make(map[string]bool, len(e.OTLP)+len(e.Prometheus))
+	uniqueNames := make(map[string]bool, len(e.OTLP)+len(e.Prometheus)+len(e.File)+len(e.Zipkin)+len(e.StatsD)+len(e.Custom))
 	for idx, ecfg := range e.OTLP {
 		if uniqueNames[ecfg.Name] {
 			return fmt.Errorf("OTLP exporter with duplicate name: %s (at idx %d)", ecfg.Name, idx)
 		}
 		uniqueNames[ecfg.Name] = true
+		if err := ecfg.Validate(); err != nil {
+			return fmt.Errorf("OTLP exporter %s (at idx %d): %s", ecfg.Name, idx, err.Error())
+		}
 	}
 	for idx, ecfg := range e.Prometheus {
 		if uniqueNames[ecfg.Name] {
@@ -105,17 +169,264 @@ func (e *Exporters) Validate() error {
 		}
 		uniqueNames[ecfg.Name] = true
 	}
+	for idx, ecfg := range e.File {
+		if uniqueNames[ecfg.Name] {
+			return fmt.Errorf("file exporter with duplicate name: %s (at idx %d)", ecfg.Name, idx)
+		}
+		uniqueNames[ecfg.Name] = true
+		if err := ecfg.Validate(); err != nil {
+			return fmt.Errorf("file exporter %s (at idx %d): %s", ecfg.Name, idx, err.Error())
+		}
+	}
+	for idx, ecfg := range e.Zipkin {
+		if uniqueNames[ecfg.Name] {
+			return fmt.Errorf("zipkin exporter with duplicate name: %s (at idx %d)", ecfg.Name, idx)
+		}
+		uniqueNames[ecfg.Name] = true
+		if err := ecfg.Validate(); err != nil {
+			return fmt.Errorf("zipkin exporter %s (at idx %d): %s", ecfg.Name, idx, err.Error())
+		}
+	}
+	for idx, ecfg := range e.StatsD {
+		if uniqueNames[ecfg.Name] {
+			return fmt.Errorf("statsd exporter with duplicate name: %s (at idx %d)", ecfg.Name, idx)
+		}
+		uniqueNames[ecfg.Name] = true
+		if err := ecfg.Validate(); err != nil {
+			return fmt.Errorf("statsd exporter %s (at idx %d): %s", ecfg.Name, idx, err.Error())
+		}
+	}
+	for idx, ecfg := range e.Custom {
+		if uniqueNames[ecfg.Name] {
+			return fmt.Errorf("custom exporter with duplicate name: %s (at idx %d)", ecfg.Name, idx)
+		}
+		uniqueNames[ecfg.Name] = true
+		if ecfg.Type == "" {
+			return fmt.Errorf("custom exporter %s (at idx %d) without type", ecfg.Name, idx)
+		}
+	}
 	return nil
 }
 
+// CustomExporter holds the configuration for an exporter created by
+// a registered factory: Type selects the factory, and the full JSON
+// object of the entry is kept in Config, to be decoded by it.
+type CustomExporter struct {
+	Name   string
+	Type   string
+	Config json.RawMessage
+}
+
+func (c *CustomExporter) UnmarshalJSON(b []byte) error {
+	var header struct {
+		Name string `json:"name"`
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(b, &header); err != nil {
+		return err
+	}
+	c.Name = header.Name
+	c.Type = header.Type
+	c.Config = append(json.RawMessage(nil), b...)
+	return nil
+}
+
+func (c CustomExporter) MarshalJSON() ([]byte, error) {
+	if len(c.Config) > 0 {
+		return c.Config, nil
+	}
+	return json.Marshal(map[string]string{
+		"name": c.Name,
+		"type": c.Type,
+	})
+}
+
+// OTLPExporter defines the connection to an OpenTelemetry collector.
+//
+// Headers are sent with every export request, and its values can
+// reference environment variables or files (see [ResolveValue]) to
+// keep secrets, like API keys, out of the configuration.
+//
+// Auth allows to obtain credentials that are refreshed over time,
+// for collectors behind an authentication gateway.
+//
+// Compression can be "gzip" or "none" (the default), and Timeout
+// is the max time to wait for a single export request (that
+// might be retried according to the Retry settings).
+//
+// URLPath is the prefix where the collector is mounted when using
+// http: traces, metrics and logs are sent to the "/v1/traces", "/v1/metrics"
+// and "/v1/logs" paths under it.
+//
+// Unlike metrics and traces, logs are not sent unless EnableLogs is set,
+// as not all collectors accept them.
 type OTLPExporter struct {
-	Name                        string `json:"name"`
-	Host                        string `json:"host"`
-	Port                        int    `json:"port"`
-	UseHTTP                     bool   `json:"use_http"`
-	DisableMetrics              bool   `json:"disable_metrics"`
-	DisableTraces               bool   `json:"disable_traces"`
-	CustomMetricReportingPeriod uint   `json:"custom_metric_reporting_period"`
+	Name                        string            `json:"name"`
+	Host                        string            `json:"host"`
+	Port                        int               `json:"port"`
+	UseHTTP                     bool              `json:"use_http"`
+	DisableMetrics              bool              `json:"disable_metrics"`
+	DisableTraces               bool              `json:"disable_traces"`
+	EnableLogs                  bool              `json:"enable_logs"`
+	CustomMetricReportingPeriod uint              `json:"custom_metric_reporting_period"`
+	TLS                         *TLSOpts          `json:"tls"`
+	Headers                     map[string]string `json:"headers"`
+	Auth                        *AuthOpts         `json:"auth"`
+	Compression                 string            `json:"compression"`
+	Timeout                     string            `json:"timeout"`
+	Retry                       *RetryOpts        `json:"retry"`
+	URLPath                     string            `json:"url_path"`
+	Batch                       *BatchOpts        `json:"batch"`
+}
+
+// Validate checks that the tuning options have valid values.
+func (e *OTLPExporter) Validate() error {
+	switch e.Compression {
+	case "", "none", "gzip":
+	default:
+		return fmt.Errorf("unknown compression %q", e.Compression)
+	}
+	if _, err := ParseDuration(e.Timeout, 0); err != nil {
+		return fmt.Errorf("bad timeout: %s", err.Error())
+	}
+	if e.URLPath != "" && !e.UseHTTP {
+		return fmt.Errorf("url_path can only be used with use_http")
+	}
+	if e.Auth != nil && e.Auth.OAuth2 != nil && !e.Auth.OAuth2.Insecure && !e.UsesTLS() {
+		return fmt.Errorf("auth.oauth2 requires an https:// host or the tls options, unless its insecure option is set")
+	}
+	if err := e.Retry.Validate(); err != nil {
+		return err
+	}
+	return e.Batch.Validate()
+}
+
+// UsesTLS tells if the connection to the collector is secured with
+// TLS, that is enabled with the "https://" scheme in the host, or by
+// setting any of the TLS options.
+func (e *OTLPExporter) UsesTLS() bool {
+	return e.TLS != nil || strings.HasPrefix(e.Host, "https://")
+}
+
+// BatchOpts defines how spans are queued and grouped before being
+// sent to an exporter. Unset values use the SDK defaults.
+//
+// MaxQueueSize is the max number of spans waiting to be exported: once
+// the queue is full, new spans are dropped. MaxExportBatchSize is the
+// max number of spans sent in a single export request.
+//
+// BatchTimeout is the max time to wait for a batch to be filled before
+// sending it, and ExportTimeout the max time an export can take.
+//
+// Synchronous sends each span to the exporter as soon as it ends,
+// without batching: it has a big performance impact, and must only
+// be used for debugging.
+type BatchOpts struct {
+	MaxQueueSize       int    `json:"max_queue_size"`
+	MaxExportBatchSize int    `json:"max_export_batch_size"`
+	BatchTimeout       string `json:"batch_timeout"`
+	ExportTimeout      string `json:"export_timeout"`
+	Synchronous        bool   `json:"synchronous"`
+}
+
+// Validate checks the durations and sizes of the batch options.
+func (b *BatchOpts) Validate() error {
+	if b == nil {
+		return nil
+	}
+	if b.MaxQueueSize < 0 || b.MaxExportBatchSize < 0 {
+		return fmt.Errorf("batch sizes cannot be negative")
+	}
+	if b.MaxQueueSize > 0 && b.MaxExportBatchSize > b.MaxQueueSize {
+		return fmt.Errorf("batch max_export_batch_size (%d) cannot be greater than max_queue_size (%d)",
+			b.MaxExportBatchSize, b.MaxQueueSize)
+	}
+	if _, err := ParseDuration(b.BatchTimeout, 0); err != nil {
+		return fmt.Errorf("bad batch batch_timeout: %s", err.Error())
+	}
+	if _, err := ParseDuration(b.ExportTimeout, 0); err != nil {
+		return fmt.Errorf("bad batch export_timeout: %s", err.Error())
+	}
+	return nil
+}
+
+// RetryOpts defines how failed exports are retried, using an
+// exponential backoff from InitialInterval up to MaxInterval between
+// attempts, and giving up after MaxElapsedTime.
+//
+// When the Retry options are not set, the SDK defaults are used (retry
+// enabled, with 5s, 30s and 1m values). Retry is only disabled with an
+// explicit "enabled": false.
+type RetryOpts struct {
+	Enabled         *bool  `json:"enabled"`
+	InitialInterval string `json:"initial_interval"`
+	MaxInterval     string `json:"max_interval"`
+	MaxElapsedTime  string `json:"max_elapsed_time"`
+}
+
+// IsEnabled tells if the failed exports must be retried, that
+// is the default when Enabled is not set.
+func (r *RetryOpts) IsEnabled() bool {
+	return r == nil || r.Enabled == nil || *r.Enabled
+}
+
+// Validate checks that all durations can be parsed.
+func (r *RetryOpts) Validate() error {
+	if r == nil {
+		return nil
+	}
+	durations := map[string]string{
+		"initial_interval": r.InitialInterval,
+		"max_interval":     r.MaxInterval,
+		"max_elapsed_time": r.MaxElapsedTime,
+	}
+	for k, v := range durations {
+		if _, err := ParseDuration(v, 0); err != nil {
+			return fmt.Errorf("bad retry %s: %s", k, err.Error())
+		}
+	}
+	return nil
+}
+
+// AuthOpts defines how to authenticate the requests sent
+// to a collector.
+type AuthOpts struct {
+	OAuth2 *OAuth2Opts `json:"oauth2"`
+}
+
+// OAuth2Opts defines the OAuth2 client credentials flow settings
+// to obtain the access token sent with every export request.
+//
+// The ClientSecret can reference an environment variable or a
+// file (see [ResolveValue]).
+//
+// The token is only sent to collectors that use TLS, unless Insecure
+// is set (like for a collector running in the same host).
+type OAuth2Opts struct {
+	TokenURL     string   `json:"token_url"`
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	Scopes       []string `json:"scopes"`
+	Insecure     bool     `json:"insecure"`
+}
+
+// TLSOpts defines the TLS settings used to connect to a collector.
+//
+// CAFile is the PEM encoded certificate authority used to verify the
+// collector certificate (if not set, the system pool is used).
+//
+// CertFile and KeyFile are the PEM encoded client certificate and
+// key, used when the collector requires mutual TLS (mTLS).
+//
+// ServerName overrides the name used to verify the collector
+// certificate, and InsecureSkipVerify disables that verification
+// (only to be used for testing).
+type TLSOpts struct {
+	CAFile             string `json:"ca_file"`
+	CertFile           string `json:"cert_file"`
+	KeyFile            string `json:"key_file"`
+	ServerName         string `json:"server_name"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"`
 }
 
 type PrometheusExporter struct {
@@ -127,6 +438,119 @@ type PrometheusExporter struct {
 	DisableMetrics bool   `json:"disable_metrics"`
 }
 
+// FileExporter writes traces and metrics as OTLP JSON lines (one
+// export request per line) to the standard output, when Path is empty
+// or "stdout", or to a file.
+//
+// When MaxSizeMB is set, the file is rotated once it grows over that
+// size: the current file is renamed with a ".1" suffix (shifting the
+// previous ones) and only MaxBackups of those files are kept.
+type FileExporter struct {
+	Name                        string     `json:"name"`
+	Path                        string     `json:"path"`
+	MaxSizeMB                   int        `json:"max_size_mb"`
+	MaxBackups                  int        `json:"max_backups"`
+	DisableMetrics              bool       `json:"disable_metrics"`
+	DisableTraces               bool       `json:"disable_traces"`
+	CustomMetricReportingPeriod uint       `json:"custom_metric_reporting_period"`
+	Batch                       *BatchOpts `json:"batch"`
+}
+
+// Validate checks the rotation and batch options.
+func (e *FileExporter) Validate() error {
+	if e.MaxSizeMB < 0 || e.MaxBackups < 0 {
+		return fmt.Errorf("max_size_mb and max_backups cannot be negative")
+	}
+	if e.MaxSizeMB > 0 && (e.Path == "" || e.Path == "stdout") {
+		return fmt.Errorf("max_size_mb can only be used when writing to a file")
+	}
+	return e.Batch.Validate()
+}
+
+// ZipkinExporter sends the traces to a Zipkin collector, using the
+// v2 JSON API at URL (like "http://localhost:9411/api/v2/spans").
+//
+// Headers values can reference environment variables or files (see
+// [ResolveValue]), and Timeout is the max time to wait for a
+// single export request (10s by default).
+type ZipkinExporter struct {
+	Name          string            `json:"name"`
+	URL           string            `json:"url"`
+	Headers       map[string]string `json:"headers"`
+	Timeout       string            `json:"timeout"`
+	DisableTraces bool              `json:"disable_traces"`
+	Batch         *BatchOpts        `json:"batch"`
+}
+
+// Validate checks the collector url, the timeout and the batch options.
+func (e *ZipkinExporter) Validate() error {
+	u, err := url.Parse(e.URL)
+	if err != nil {
+		return fmt.Errorf("bad url: %s", err.Error())
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("url must be an http:// or https:// address, got %q", e.URL)
+	}
+	if _, err := ParseDuration(e.Timeout, 0); err != nil {
+		return fmt.Errorf("bad timeout: %s", err.Error())
+	}
+	return e.Batch.Validate()
+}
+
+// StatsDExporter sends the metrics to a StatsD agent.
+//
+// Address can be a "host:port" (or "udp://host:port") UDP address, or a
+// "unix:///path/to/socket" unix datagram socket (127.0.0.1:8125 by default).
+//
+// Prefix is prepended as is to all metric names (like "krakend."), and
+// TagFormat selects how the attributes are sent: "dogstatsd" (the
+// default) tags, "influxdb" tags in the metric name, or "none" to
+// drop them.
+//
+// Histograms are sent as timers ("timer", the default, with durations in
+// milliseconds), "histogram" or "distribution" (DogStatsD) metrics,
+// according to HistogramType.
+//
+// Metric lines are grouped in packets of up to MaxPacketSize
+// bytes (1432 by default).
+type StatsDExporter struct {
+	Name                        string `json:"name"`
+	Address                     string `json:"address"`
+	Prefix                      string `json:"prefix"`
+	TagFormat                   string `json:"tag_format"`
+	HistogramType               string `json:"histogram_type"`
+	MaxPacketSize               int    `json:"max_packet_size"`
+	DisableMetrics              bool   `json:"disable_metrics"`
+	CustomMetricReportingPeriod uint   `json:"custom_metric_reporting_period"`
+}
+
+// Validate checks the address and the known formats.
+func (e *StatsDExporter) Validate() error {
+	if strings.HasPrefix(e.Address, "unix://") {
+		if strings.TrimPrefix(e.Address, "unix://") == "" {
+			return fmt.Errorf("missing unix socket path")
+		}
+	} else if e.Address != "" {
+		if _, _, err := net.SplitHostPort(strings.TrimPrefix(e.Address, "udp://")); err != nil {
+			return fmt.Errorf("bad address: %s", err.Error())
+		}
+	}
+	switch e.TagFormat {
+	case "", "dogstatsd", "influxdb", "none":
+	default:
+		return fmt.Errorf("unknown tag_format %q", e.TagFormat)
+	}
+	switch e.HistogramType {
+	case "", "timer", "histogram", "distribution":
+	default:
+		return fmt.Errorf("unknown histogram_type %q", e.HistogramType)
+	}
+	if e.MaxPacketSize < 0 {
+		return fmt.Errorf("max_packet_size cannot be negative")
+	}
+	return nil
+}
+
 // LayersOpts contains the level of telemetry detail
 // that we want for each KrakenD stage
 type LayersOpts struct {
@@ -138,16 +562,124 @@ type LayersOpts struct {
 // GlobalOpts has the options for the KrakenD
 // http handler stage.
 // We can select if we want to disable the metrics,
-// the traces, and / or the trace propagation.
+// the traces, the access logs, and / or the trace propagation.
 type GlobalOpts struct {
 	DisableMetrics          bool       `json:"disable_metrics"`
 	DisableTraces           bool       `json:"disable_traces"`
+	DisableLogs             bool       `json:"disable_logs"`
 	DisablePropagation      bool       `json:"disable_propagation"`
 	ReportHeaders           bool       `json:"report_headers"`
 	SkipHeaders             []string   `json:"skip_headers"`
 	MetricsStaticAttributes Attributes `json:"metrics_static_attributes"`
 	TracesStaticAttributes  Attributes `json:"traces_static_attributes"`
 	SemConv                 string     `json:"semantic_convention"`
+
+	ForceSampling *ForceSamplingOpts `json:"force_sampling"`
+	// Propagators overrides the ones used to extract the
+	// context of the incoming requests.
+	Propagators []string `json:"propagators"`
+	// TrustIncomingContext selects the incoming requests whose trace
+	// context is continued (see the TrustContext* values), and
+	// TrustedContextSources the IPs or CIDRs trusted with "trusted".
+	TrustIncomingContext  string   `json:"trust_incoming_context"`
+	TrustedContextSources []string `json:"trusted_context_sources"`
+	// TraceResponse adds the W3C "traceresponse" header to the
+	// responses, and TraceIDHeader a header (like "X-Trace-Id")
+	// with the trace ID.
+	TraceResponse bool   `json:"trace_response"`
+	TraceIDHeader string `json:"trace_id_header"`
+
+	BaggageAttributes *BaggageAttributesOpts `json:"baggage_attributes"`
+}
+
+// BaggageAttributesOpts copies the values of the Keys in the incoming
+// baggage as attributes of the server, proxy and backend spans, and when
+// Metrics is enabled, also of their metrics. The attributes are named
+// "baggage.<key>", so they cannot replace the standard ones.
+//
+// To limit the cardinality of the metrics, only MaxValuesPerKey (100
+// by default) different values of each key are reported, and the rest
+// of the values are reported as "_other".
+type BaggageAttributesOpts struct {
+	Keys            []string `json:"keys"`
+	Metrics         bool     `json:"metrics"`
+	MaxValuesPerKey int      `json:"max_values_per_key"`
+}
+
+// Validate checks that the cardinality limit is not negative.
+func (o *BaggageAttributesOpts) Validate() error {
+	if o == nil {
+		return nil
+	}
+	if o.MaxValuesPerKey < 0 {
+		return fmt.Errorf("baggage_attributes max_values_per_key cannot be negative")
+	}
+	return nil
+}
+
+// Values for the TrustIncomingContext setting.
+const (
+	// TrustContextAlways continues the trace of any request (the default).
+	TrustContextAlways = "always"
+	// TrustContextNever starts a new trace for every request, with
+	// a link to the incoming context.
+	TrustContextNever = "never"
+	// TrustContextTrusted only continues the trace of the requests
+	// that come from the trusted proxies or the TrustedContextSources.
+	TrustContextTrusted = "trusted"
+)
+
+// Validate checks the options of the global layer.
+func (o *GlobalOpts) Validate() error {
+	if o == nil {
+		return nil
+	}
+	if err := o.ForceSampling.Validate(); err != nil {
+		return err
+	}
+	if err := ValidatePropagators(o.Propagators); err != nil {
+		return err
+	}
+	if err := o.BaggageAttributes.Validate(); err != nil {
+		return err
+	}
+	switch o.TrustIncomingContext {
+	case "", TrustContextAlways, TrustContextNever, TrustContextTrusted:
+	default:
+		return fmt.Errorf("unknown trust_incoming_context %q", o.TrustIncomingContext)
+	}
+	if _, err := ParseCIDRs(o.TrustedContextSources); err != nil {
+		return fmt.Errorf("bad trusted_context_sources: %s", err.Error())
+	}
+	return nil
+}
+
+// ParseCIDRs parses a list of IPs or CIDRs, returning
+// the networks for them.
+func ParseCIDRs(sources []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(sources))
+	for _, src := range sources {
+		src = strings.TrimSpace(src)
+		if !strings.Contains(src, "/") {
+			ip := net.ParseIP(src)
+			if ip == nil {
+				return nil, fmt.Errorf("bad IP %q", src)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(src)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
 }
 
 // PipeOpts has the options for the KrakenD pipe stage
@@ -258,6 +790,12 @@ type BackendTraceOpts struct {
 	StaticAttributes   Attributes `json:"static_attributes"`
 	ReportHeaders      bool       `json:"report_headers"`
 	SkipHeaders        []string   `json:"skip_headers"`
+
+	// DisablePropagation stops the injection of the trace context
+	// into the backend requests (the span is still recorded), and
+	// Propagators overrides the formats used to inject it.
+	DisablePropagation bool     `json:"disable_propagation"`
+	Propagators        []string `json:"propagators"`
 }
 
 // Enabled tells if there are any traces to be reported.
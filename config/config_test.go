@@ -0,0 +1,45 @@
+package config
+
+import (
+	"testing"
+)
+
+func TestGlobalOpts_validate(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		opts  *GlobalOpts
+		valid bool
+	}{
+		{"nil", nil, true},
+		{"trusted", &GlobalOpts{TrustIncomingContext: TrustContextTrusted, TrustedContextSources: []string{"10.0.0.0/8", "::1"}}, true},
+		{"bad_policy", &GlobalOpts{TrustIncomingContext: "sometimes"}, false},
+		{"bad_source", &GlobalOpts{TrustIncomingContext: TrustContextTrusted, TrustedContextSources: []string{"10.0.0.0/33"}}, false},
+		{"bad_propagator", &GlobalOpts{Propagators: []string{"w3c"}}, false},
+		{"bad_baggage_limit", &GlobalOpts{BaggageAttributes: &BaggageAttributesOpts{Keys: []string{"tenant"}, MaxValuesPerKey: -1}}, false},
+	} {
+		if err := tc.opts.Validate(); (err == nil) != tc.valid {
+			t.Errorf("%s: expected valid %v, got error %v", tc.name, tc.valid, err)
+		}
+	}
+}
+
+func TestOTLPExporter_validateOAuth2(t *testing.T) {
+	oauth2 := &AuthOpts{OAuth2: &OAuth2Opts{TokenURL: "https://auth.example.com/token"}}
+	insecure := &AuthOpts{OAuth2: &OAuth2Opts{TokenURL: "https://auth.example.com/token", Insecure: true}}
+	for _, tc := range []struct {
+		name  string
+		exp   OTLPExporter
+		valid bool
+	}{
+		{"plaintext", OTLPExporter{Host: "collector", Auth: oauth2}, false},
+		{"plaintext_http", OTLPExporter{Host: "http://collector", UseHTTP: true, Auth: oauth2}, false},
+		{"https", OTLPExporter{Host: "https://collector", UseHTTP: true, Auth: oauth2}, true},
+		{"tls", OTLPExporter{Host: "collector", TLS: &TLSOpts{}, Auth: oauth2}, true},
+		{"insecure_opt_in", OTLPExporter{Host: "localhost", Auth: insecure}, true},
+		{"no_auth", OTLPExporter{Host: "collector"}, true},
+	} {
+		if err := tc.exp.Validate(); (err == nil) != tc.valid {
+			t.Errorf("%s: expected valid %v, got error %v", tc.name, tc.valid, err)
+		}
+	}
+}
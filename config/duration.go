@@ -0,0 +1,14 @@
+package config
+
+import (
+	"time"
+)
+
+// ParseDuration parses a duration string (like "500ms", or "1m30s"),
+// returning the provided default value when it is empty.
+func ParseDuration(v string, defaultValue time.Duration) (time.Duration, error) {
+	if v == "" {
+		return defaultValue, nil
+	}
+	return time.ParseDuration(v)
+}
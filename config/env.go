@@ -0,0 +1,283 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Values for the EnvPrecedence setting.
+const (
+	// EnvPrecedenceOverride makes the OTEL_* environment variables
+	// replace the values from the configuration.
+	EnvPrecedenceOverride = "override"
+	// EnvPrecedenceFallback only uses the OTEL_* environment variables
+	// for the values that are not set in the configuration.
+	EnvPrecedenceFallback = "fallback"
+	// EnvPrecedenceIgnore does not use the OTEL_* environment variables.
+	EnvPrecedenceIgnore = "ignore"
+)
+
+// Standard OpenTelemetry environment variables that can be applied
+// to the configuration.
+const (
+	EnvServiceName        = "OTEL_SERVICE_NAME"
+	EnvTracesSampler      = "OTEL_TRACES_SAMPLER"
+	EnvTracesSamplerArg   = "OTEL_TRACES_SAMPLER_ARG"
+	EnvMetricExportPeriod = "OTEL_METRIC_EXPORT_INTERVAL"
+	EnvOTLPEndpoint       = "OTEL_EXPORTER_OTLP_ENDPOINT"
+	EnvOTLPProtocol       = "OTEL_EXPORTER_OTLP_PROTOCOL"
+	EnvOTLPHeaders        = "OTEL_EXPORTER_OTLP_HEADERS"
+	EnvOTLPTimeout        = "OTEL_EXPORTER_OTLP_TIMEOUT"
+	EnvOTLPCompression    = "OTEL_EXPORTER_OTLP_COMPRESSION"
+)
+
+// EnvOTLPExporterName is the name of the OTLP exporter created from the
+// OTEL_EXPORTER_OTLP_ENDPOINT variable when none is configured.
+const EnvOTLPExporterName = "otlp_env"
+
+// ApplyEnv applies the standard OTEL_* environment variables to the
+// configuration, according to EnvPrecedence:
+//   - "fallback" (the default): configuration > environment > defaults.
+//   - "override": environment > configuration > defaults.
+//   - "ignore": the environment variables are not used.
+//
+// The OTEL_EXPORTER_OTLP_* variables are applied to all the OTLP exporters,
+// and when there are none, an exporter named "otlp_env" is created if
+// OTEL_EXPORTER_OTLP_ENDPOINT is set. The endpoint is only applied to the
+// exporter named "otlp_env", or to the single OTLP exporter when there is
+// no exporter with that name, so the others keep their collectors.
+//
+// The fields set from the environment are recorded, to be reported
+// by [ConfigData.ValueSources] and in validation errors.
+func (c *ConfigData) ApplyEnv() error {
+	switch c.EnvPrecedence {
+	case EnvPrecedenceIgnore:
+		return nil
+	case "", EnvPrecedenceOverride, EnvPrecedenceFallback:
+	default:
+		return fmt.Errorf("unknown env_precedence %q", c.EnvPrecedence)
+	}
+	override := c.EnvPrecedence == EnvPrecedenceOverride
+
+	if v, ok := lookupEnv(EnvServiceName); ok && (override || c.ServiceName == "") {
+		c.ServiceName = v
+		c.setSource("service_name", EnvServiceName)
+	}
+
+	if rate, parentBased, src, ok, err := envSampleRate(); err != nil {
+		return err
+	} else if ok && rate == 0 && (override || (c.TraceSampleRate == nil && c.Sampler == nil)) {
+		// a zero rate in the configuration samples all the traces
+		c.Sampler = &SamplerOpts{Type: SamplerTypeAlwaysOff, ParentBased: &parentBased}
+		c.setSource("sampler", src)
+	} else if ok && rate > 0 && (override || c.TraceSampleRate == nil) {
+		c.TraceSampleRate = &rate
+		c.setSource("trace_sample_rate", src)
+	}
+
+	if v, ok := lookupEnv(EnvMetricExportPeriod); ok && (override || c.MetricReportingPeriod == nil) {
+		ms, err := strconv.Atoi(v)
+		if err != nil || ms <= 0 {
+			return fmt.Errorf("%s: bad interval in milliseconds %q", EnvMetricExportPeriod, v)
+		}
+		secs := ms / 1000
+		if secs < 1 {
+			secs = 1
+		}
+		c.MetricReportingPeriod = &secs
+		c.setSource("metric_reporting_period", EnvMetricExportPeriod)
+	}
+
+	if len(c.Exporters.OTLP) == 0 {
+		if _, ok := lookupEnv(EnvOTLPEndpoint); !ok {
+			return nil
+		}
+		c.Exporters.OTLP = []OTLPExporter{{Name: EnvOTLPExporterName}}
+		// all the values of a new exporter come from the env
+		override = true
+	}
+	endpointIdx := -1
+	for idx, e := range c.Exporters.OTLP {
+		if e.Name == EnvOTLPExporterName {
+			endpointIdx = idx
+			break
+		}
+	}
+	if endpointIdx < 0 && len(c.Exporters.OTLP) == 1 {
+		endpointIdx = 0
+	}
+	for idx := range c.Exporters.OTLP {
+		if err := c.applyOTLPEnv(idx, override, idx == endpointIdx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *ConfigData) applyOTLPEnv(idx int, override, endpoint bool) error {
+	e := &c.Exporters.OTLP[idx]
+	field := func(name string) string {
+		return fmt.Sprintf("exporters.otlp[%d].%s", idx, name)
+	}
+
+	if v, ok := lookupEnv(EnvOTLPProtocol); ok {
+		var useHTTP bool
+		switch v {
+		case "grpc":
+		case "http/protobuf":
+			useHTTP = true
+		default:
+			return fmt.Errorf("%s: unsupported protocol %q", EnvOTLPProtocol, v)
+		}
+		// use_http is a boolean, so we cannot know if it has been
+		// set in the configuration: it can only be overridden.
+		if override {
+			e.UseHTTP = useHTTP
+			c.setSource(field("use_http"), EnvOTLPProtocol)
+		}
+	}
+
+	if v, ok := lookupEnv(EnvOTLPEndpoint); ok && endpoint && (override || (e.Host == "" && e.Port == 0)) {
+		u, err := url.Parse(v)
+		if err != nil || u.Hostname() == "" {
+			return fmt.Errorf("%s: bad endpoint %q", EnvOTLPEndpoint, v)
+		}
+		port := 4317
+		if e.UseHTTP {
+			port = 4318
+		}
+		if u.Port() != "" {
+			if port, err = strconv.Atoi(u.Port()); err != nil {
+				return fmt.Errorf("%s: bad port in %q", EnvOTLPEndpoint, v)
+			}
+		}
+		// the host keeps the brackets of an IPv6 address
+		e.Host = u.Scheme + "://" + strings.TrimSuffix(u.Host, ":"+u.Port())
+		e.Port = port
+		c.setSource(field("host"), EnvOTLPEndpoint)
+		c.setSource(field("port"), EnvOTLPEndpoint)
+		if p := strings.Trim(u.Path, "/"); p != "" && e.UseHTTP {
+			e.URLPath = p
+			c.setSource(field("url_path"), EnvOTLPEndpoint)
+		}
+	}
+
+	if v, ok := lookupEnv(EnvOTLPHeaders); ok && (override || len(e.Headers) == 0) {
+		headers, err := parseEnvHeaders(v)
+		if err != nil {
+			return fmt.Errorf("%s: %s", EnvOTLPHeaders, err.Error())
+		}
+		e.Headers = headers
+		c.setSource(field("headers"), EnvOTLPHeaders)
+	}
+
+	if v, ok := lookupEnv(EnvOTLPTimeout); ok && (override || e.Timeout == "") {
+		ms, err := strconv.Atoi(v)
+		if err != nil || ms < 0 {
+			return fmt.Errorf("%s: bad timeout in milliseconds %q", EnvOTLPTimeout, v)
+		}
+		e.Timeout = strconv.Itoa(ms) + "ms"
+		c.setSource(field("timeout"), EnvOTLPTimeout)
+	}
+
+	if v, ok := lookupEnv(EnvOTLPCompression); ok && (override || e.Compression == "") {
+		e.Compression = v
+		c.setSource(field("compression"), EnvOTLPCompression)
+	}
+	return nil
+}
+
+// envSampleRate returns the sample rate defined with the
+// OTEL_TRACES_SAMPLER and OTEL_TRACES_SAMPLER_ARG variables (where
+// 0 means not sampling the traces), and if the sampler follows the
+// decision of the remote parents.
+func envSampleRate() (float64, bool, string, bool, error) {
+	sampler, hasSampler := lookupEnv(EnvTracesSampler)
+	arg, hasArg := lookupEnv(EnvTracesSamplerArg)
+	if !hasSampler && !hasArg {
+		return 0, false, "", false, nil
+	}
+
+	switch sampler {
+	case "always_on", "parentbased_always_on":
+		return 1.0, true, EnvTracesSampler, true, nil
+	case "always_off", "parentbased_always_off":
+		return 0, sampler != "always_off", EnvTracesSampler, true, nil
+	case "", "traceidratio", "parentbased_traceidratio":
+		if !hasArg {
+			return 1.0, true, EnvTracesSampler, true, nil
+		}
+		rate, err := strconv.ParseFloat(arg, 64)
+		if err != nil || rate < 0 || rate > 1 {
+			return 0, false, "", false, fmt.Errorf("%s: bad sample rate %q", EnvTracesSamplerArg, arg)
+		}
+		return rate, sampler != "traceidratio", EnvTracesSamplerArg, true, nil
+	}
+	return 0, false, "", false, fmt.Errorf("%s: unsupported sampler %q", EnvTracesSampler, sampler)
+}
+
+// parseEnvHeaders parses the "key1=value1,key2=value2" format, with
+// url encoded values, used by OTEL_EXPORTER_OTLP_HEADERS.
+func parseEnvHeaders(v string) (map[string]string, error) {
+	headers := map[string]string{}
+	for _, kv := range strings.Split(v, ",") {
+		if strings.TrimSpace(kv) == "" {
+			continue
+		}
+		k, val, ok := strings.Cut(kv, "=")
+		k = strings.TrimSpace(k)
+		if !ok || k == "" {
+			return nil, fmt.Errorf("bad header %q", kv)
+		}
+		dv, err := url.PathUnescape(strings.TrimSpace(val))
+		if err != nil {
+			return nil, fmt.Errorf("bad header value for %s: %s", k, err.Error())
+		}
+		headers[k] = dv
+	}
+	return headers, nil
+}
+
+func lookupEnv(name string) (string, bool) {
+	v, ok := os.LookupEnv(name)
+	if !ok || strings.TrimSpace(v) == "" {
+		return "", false
+	}
+	return strings.TrimSpace(v), true
+}
+
+func (c *ConfigData) setSource(field, envVar string) {
+	if c.sources == nil {
+		c.sources = map[string]string{}
+	}
+	c.sources[field] = envVar
+}
+
+// ValueSources returns the fields of the configuration that have been
+// set from an environment variable, and the name of that variable. The
+// rest of values come from the configuration or are the defaults.
+func (c *ConfigData) ValueSources() map[string]string {
+	res := make(map[string]string, len(c.sources))
+	for k, v := range c.sources {
+		res[k] = v
+	}
+	return res
+}
+
+// withSources adds the list of values set from the environment
+// to a validation error.
+func (c *ConfigData) withSources(err error) error {
+	if err == nil || len(c.sources) == 0 {
+		return err
+	}
+	fields := make([]string, 0, len(c.sources))
+	for k, v := range c.sources {
+		fields = append(fields, k+" from "+v)
+	}
+	sort.Strings(fields)
+	return fmt.Errorf("%s (values set from the environment: %s)", err.Error(), strings.Join(fields, ", "))
+}
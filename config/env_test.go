@@ -0,0 +1,310 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	luraconfig "github.com/luraproject/lura/v2/config"
+)
+
+func setTestEnv(t *testing.T) {
+	t.Helper()
+	t.Setenv(EnvServiceName, "env-service")
+	t.Setenv(EnvTracesSampler, "parentbased_traceidratio")
+	t.Setenv(EnvTracesSamplerArg, "0.25")
+	t.Setenv(EnvMetricExportPeriod, "15000")
+	t.Setenv(EnvOTLPEndpoint, "https://collector.example.com:4318/otlp")
+	t.Setenv(EnvOTLPProtocol, "http/protobuf")
+	t.Setenv(EnvOTLPHeaders, "api-key=s3cr3t,x-tenant=a%20b")
+	t.Setenv(EnvOTLPTimeout, "2500")
+	t.Setenv(EnvOTLPCompression, "gzip")
+}
+
+func TestApplyEnv_ignore(t *testing.T) {
+	setTestEnv(t)
+	cfg := &ConfigData{ServiceName: "json-service", EnvPrecedence: EnvPrecedenceIgnore}
+	if err := cfg.ApplyEnv(); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	if cfg.ServiceName != "json-service" || cfg.TraceSampleRate != nil || len(cfg.Exporters.OTLP) != 0 {
+		t.Errorf("the environment must be ignored: %+v", cfg)
+	}
+}
+
+func TestApplyEnv_defaultFallback(t *testing.T) {
+	setTestEnv(t)
+	cfg := &ConfigData{ServiceName: "json-service"}
+	if err := cfg.ApplyEnv(); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	if cfg.ServiceName != "json-service" {
+		t.Errorf("the configured service name must be kept, got: %s", cfg.ServiceName)
+	}
+	if cfg.TraceSampleRate == nil || *cfg.TraceSampleRate != 0.25 {
+		t.Errorf("expected the sample rate from the environment by default")
+	}
+}
+
+func TestApplyEnv_override(t *testing.T) {
+	setTestEnv(t)
+	rate := 1.0
+	cfg := &ConfigData{
+		ServiceName:     "json-service",
+		TraceSampleRate: &rate,
+		EnvPrecedence:   EnvPrecedenceOverride,
+		Exporters: Exporters{
+			OTLP: []OTLPExporter{{Name: "local", Host: "localhost", Port: 4317, Compression: "none"}},
+		},
+	}
+	if err := cfg.ApplyEnv(); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	if cfg.ServiceName != "env-service" {
+		t.Errorf("unexpected service name: %s", cfg.ServiceName)
+	}
+	if *cfg.TraceSampleRate != 0.25 {
+		t.Errorf("unexpected sample rate: %f", *cfg.TraceSampleRate)
+	}
+	if *cfg.MetricReportingPeriod != 15 {
+		t.Errorf("unexpected reporting period: %d", *cfg.MetricReportingPeriod)
+	}
+
+	e := cfg.Exporters.OTLP[0]
+	if e.Name != "local" || e.Host != "https://collector.example.com" || e.Port != 4318 ||
+		!e.UseHTTP || e.URLPath != "otlp" {
+		t.Errorf("unexpected exporter endpoint: %+v", e)
+	}
+	if e.Headers["api-key"] != "s3cr3t" || e.Headers["x-tenant"] != "a b" {
+		t.Errorf("unexpected headers: %v", e.Headers)
+	}
+	if e.Timeout != "2500ms" || e.Compression != "gzip" {
+		t.Errorf("unexpected timeout or compression: %q %q", e.Timeout, e.Compression)
+	}
+
+	sources := cfg.ValueSources()
+	expectedSources := map[string]string{
+		"service_name":                  "OTEL_SERVICE_NAME",
+		"trace_sample_rate":             "OTEL_TRACES_SAMPLER_ARG",
+		"metric_reporting_period":       "OTEL_METRIC_EXPORT_INTERVAL",
+		"exporters.otlp[0].host":        "OTEL_EXPORTER_OTLP_ENDPOINT",
+		"exporters.otlp[0].use_http":    "OTEL_EXPORTER_OTLP_PROTOCOL",
+		"exporters.otlp[0].headers":     "OTEL_EXPORTER_OTLP_HEADERS",
+		"exporters.otlp[0].timeout":     "OTEL_EXPORTER_OTLP_TIMEOUT",
+		"exporters.otlp[0].compression": "OTEL_EXPORTER_OTLP_COMPRESSION",
+	}
+	for k, v := range expectedSources {
+		if sources[k] != v {
+			t.Errorf("source of %s: expected %s, got %q", k, v, sources[k])
+		}
+	}
+}
+
+func TestApplyEnv_fallback(t *testing.T) {
+	setTestEnv(t)
+	cfg := &ConfigData{
+		ServiceName:   "json-service",
+		EnvPrecedence: EnvPrecedenceFallback,
+		Exporters: Exporters{
+			OTLP: []OTLPExporter{{Name: "local", Host: "localhost", Port: 4317, Compression: "none"}},
+		},
+	}
+	if err := cfg.ApplyEnv(); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	if cfg.ServiceName != "json-service" {
+		t.Errorf("the configured service name must be kept, got: %s", cfg.ServiceName)
+	}
+	if cfg.TraceSampleRate == nil || *cfg.TraceSampleRate != 0.25 {
+		t.Errorf("expected the sample rate from the environment")
+	}
+	e := cfg.Exporters.OTLP[0]
+	if e.Host != "localhost" || e.Port != 4317 || e.UseHTTP || e.Compression != "none" {
+		t.Errorf("the configured exporter values must be kept: %+v", e)
+	}
+	if e.Timeout != "2500ms" || len(e.Headers) != 2 {
+		t.Errorf("expected unset exporter values from the environment: %+v", e)
+	}
+	if _, ok := cfg.ValueSources()["service_name"]; ok {
+		t.Errorf("service_name must not be reported as set from the environment")
+	}
+}
+
+func TestApplyEnv_newExporter(t *testing.T) {
+	t.Setenv(EnvOTLPEndpoint, "http://otel-collector:4317")
+	cfg := &ConfigData{EnvPrecedence: EnvPrecedenceFallback}
+	if err := cfg.ApplyEnv(); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	if len(cfg.Exporters.OTLP) != 1 {
+		t.Errorf("expected an exporter to be created, got %d", len(cfg.Exporters.OTLP))
+		return
+	}
+	e := cfg.Exporters.OTLP[0]
+	if e.Name != EnvOTLPExporterName || e.Host != "http://otel-collector" || e.Port != 4317 || e.UseHTTP {
+		t.Errorf("unexpected exporter: %+v", e)
+	}
+}
+
+func TestApplyEnv_endpoint(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		endpoint string
+		host     string
+		port     int
+	}{
+		{"ipv4", "http://10.0.0.1:4317", "http://10.0.0.1", 4317},
+		{"ipv6", "http://[::1]:4317", "http://[::1]", 4317},
+		{"ipv6_no_port", "https://[2001:db8::1]", "https://[2001:db8::1]", 4317},
+	} {
+		t.Setenv(EnvOTLPEndpoint, tc.endpoint)
+		cfg := &ConfigData{EnvPrecedence: EnvPrecedenceFallback}
+		if err := cfg.ApplyEnv(); err != nil {
+			t.Errorf("%s: unexpected error: %s", tc.name, err.Error())
+			continue
+		}
+		if e := cfg.Exporters.OTLP[0]; e.Host != tc.host || e.Port != tc.port {
+			t.Errorf("%s: unexpected endpoint %s:%d", tc.name, e.Host, e.Port)
+		}
+	}
+}
+
+func TestApplyEnv_endpointSelectedExporter(t *testing.T) {
+	setTestEnv(t)
+	cfg := &ConfigData{
+		EnvPrecedence: EnvPrecedenceOverride,
+		Exporters: Exporters{
+			OTLP: []OTLPExporter{
+				{Name: "local", Host: "localhost", Port: 4317},
+				{Name: EnvOTLPExporterName, Host: "collector", Port: 4317},
+			},
+		},
+	}
+	if err := cfg.ApplyEnv(); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	if e := cfg.Exporters.OTLP[0]; e.Host != "localhost" || e.Port != 4317 {
+		t.Errorf("the endpoint of the other exporters must be kept: %+v", e)
+	}
+	if e := cfg.Exporters.OTLP[1]; e.Host != "https://collector.example.com" || e.Port != 4318 {
+		t.Errorf("expected the endpoint in the %s exporter: %+v", EnvOTLPExporterName, e)
+	}
+	if cfg.Exporters.OTLP[0].Timeout != "2500ms" {
+		t.Errorf("the other variables must be applied to all the exporters")
+	}
+
+	cfg.Exporters.OTLP[1].Name = "remote"
+	cfg.Exporters.OTLP[1].Host = "collector"
+	if err := cfg.ApplyEnv(); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	for _, e := range cfg.Exporters.OTLP {
+		if strings.Contains(e.Host, "example.com") {
+			t.Errorf("the endpoint must not be applied to several exporters: %+v", e)
+		}
+	}
+}
+
+func TestApplyEnv_samplerOff(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		env         map[string]string
+		parentBased bool
+	}{
+		{"always_off", map[string]string{EnvTracesSampler: "always_off"}, false},
+		{"parentbased_always_off", map[string]string{EnvTracesSampler: "parentbased_always_off"}, true},
+		{"zero_ratio", map[string]string{EnvTracesSampler: "traceidratio", EnvTracesSamplerArg: "0"}, false},
+		{"zero_arg", map[string]string{EnvTracesSamplerArg: "0"}, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			cfg := &ConfigData{EnvPrecedence: EnvPrecedenceFallback}
+			if err := cfg.ApplyEnv(); err != nil {
+				t.Errorf("unexpected error: %s", err.Error())
+				return
+			}
+			if cfg.TraceSampleRate != nil {
+				t.Errorf("unexpected sample rate %f", *cfg.TraceSampleRate)
+			}
+			if cfg.Sampler == nil || cfg.Sampler.Type != SamplerTypeAlwaysOff ||
+				cfg.Sampler.IsParentBased() != tc.parentBased {
+				t.Errorf("expected a sampler that is always off, got %+v", cfg.Sampler)
+			}
+			if err := cfg.Validate(); err != nil {
+				t.Errorf("unexpected validation error: %s", err.Error())
+			}
+		})
+	}
+}
+
+func TestApplyEnv_errors(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		env        map[string]string
+		precedence string
+	}{
+		{"bad_precedence", nil, "always"},
+		{"bad_sampler", map[string]string{EnvTracesSampler: "jaeger_remote"}, EnvPrecedenceOverride},
+		{"bad_rate", map[string]string{EnvTracesSamplerArg: "2"}, EnvPrecedenceOverride},
+		{"bad_interval", map[string]string{EnvMetricExportPeriod: "soon"}, EnvPrecedenceOverride},
+		{"bad_protocol", map[string]string{EnvOTLPEndpoint: "http://c:4317", EnvOTLPProtocol: "http/json"}, EnvPrecedenceOverride},
+		{"bad_endpoint", map[string]string{EnvOTLPEndpoint: "collector:4317"}, EnvPrecedenceOverride},
+		{"bad_headers", map[string]string{EnvOTLPEndpoint: "http://c:4317", EnvOTLPHeaders: "novalue"}, EnvPrecedenceOverride},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			cfg := &ConfigData{EnvPrecedence: tc.precedence}
+			if err := cfg.ApplyEnv(); err == nil {
+				t.Errorf("expected error")
+			}
+		})
+	}
+}
+
+func TestValidate_reportsSources(t *testing.T) {
+	t.Setenv(EnvOTLPEndpoint, "http://otel-collector:4317")
+	t.Setenv(EnvOTLPCompression, "br")
+	cfg := &ConfigData{EnvPrecedence: EnvPrecedenceOverride}
+	if err := cfg.ApplyEnv(); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	err := cfg.Validate()
+	if err == nil {
+		t.Errorf("expected a validation error for the compression")
+		return
+	}
+	if !strings.Contains(err.Error(), "exporters.otlp[0].compression from OTEL_EXPORTER_OTLP_COMPRESSION") {
+		t.Errorf("the error does not report the source of the values: %s", err.Error())
+	}
+}
+
+func TestFromLura_env(t *testing.T) {
+	t.Setenv(EnvServiceName, "env-service")
+	srvCfg := luraconfig.ServiceConfig{
+		Name: "lura-service",
+		ExtraConfig: luraconfig.ExtraConfig{
+			Namespace: map[string]interface{}{
+				"env_precedence": "fallback",
+			},
+		},
+	}
+	cfg, err := FromLura(srvCfg)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	if cfg.ServiceName != "env-service" {
+		t.Errorf("the environment must take precedence over the Lura service name, got: %s", cfg.ServiceName)
+	}
+}
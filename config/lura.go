@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
 
 	luraconfig "github.com/luraproject/lura/v2/config"
 )
@@ -23,11 +24,17 @@ var ErrNoConfig = errors.New("no config found for opentelemetry")
 //
 // In case no "Layers" config is provided, a set of defaults with
 // everything enabled will be used.
+//
+// The standard OTEL_* environment variables are applied before setting
+// the defaults, as configured with "env_precedence" (see [ConfigData.ApplyEnv]).
 func FromLura(srvCfg luraconfig.ServiceConfig) (*ConfigData, error) {
 	cfg, err := LuraExtraCfg(srvCfg.ExtraConfig)
 	if err != nil {
 		return nil, err
 	}
+	if err := cfg.ApplyEnv(); err != nil {
+		return nil, err
+	}
 
 	if cfg.ServiceName == "" {
 		if srvCfg.Name != "" {
@@ -80,3 +87,84 @@ func LuraLayerExtraCfg(extraCfg luraconfig.ExtraConfig) (*LayersOpts, error) {
 
 	return cfg, nil
 }
+
+// ExporterSelection selects, in the "extra_config" of an endpoint or
+// a backend, the names of the exporters that receive its metrics and
+// traces, instead of the ones that report by default.
+//
+// A nil list keeps the default exporters, and an empty one disables
+// the reporting of that kind of telemetry. The exporters do not need
+// to report by default to be selected (so they can be configured with
+// "disable_metrics" or "disable_traces" to only receive the telemetry
+// of the endpoints and backends that select them).
+//
+// The selection of an endpoint also applies to the server span and
+// metrics of its requests (the access logs always use the default
+// exporters). A backend does not inherit the selection of its endpoint,
+// and selecting an exporter that does not exist is a configuration error.
+type ExporterSelection struct {
+	MetricExporters []string `json:"metric_exporters"`
+	TraceExporters  []string `json:"trace_exporters"`
+}
+
+// Empty tells if the selection keeps all the default exporters.
+func (s *ExporterSelection) Empty() bool {
+	return s == nil || (s.MetricExporters == nil && s.TraceExporters == nil)
+}
+
+// LuraExporterSelectionExtraCfg extracts the exporters selection from
+// the "extra_config" of an endpoint or backend.
+func LuraExporterSelectionExtraCfg(extraCfg luraconfig.ExtraConfig) (*ExporterSelection, error) {
+	cfg := new(ExporterSelection)
+	if err := decodeNamespace(extraCfg, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// SamplingOpts has the sampling options that can be set in the
+// "extra_config" of an endpoint or a backend.
+//
+// At the endpoint level, SampleRate replaces the global "trace_sample_rate"
+// for the traces of the requests to that endpoint.
+//
+// At the backend level, the sampling decision is taken before reaching
+// the backend, so SampleRate can only reduce the number of spans reported
+// for that backend in the traces that are sampled.
+type SamplingOpts struct {
+	SampleRate *float64 `json:"sample_rate"`
+}
+
+// Validate checks that the sample rate is between 0 and 1.
+func (o *SamplingOpts) Validate() error {
+	if o == nil || o.SampleRate == nil {
+		return nil
+	}
+	if *o.SampleRate < 0 || *o.SampleRate > 1 {
+		return fmt.Errorf("sample_rate must be between 0 and 1, got %f", *o.SampleRate)
+	}
+	return nil
+}
+
+// LuraSamplingExtraCfg extracts the sampling options from the
+// "extra_config" of an endpoint or backend.
+func LuraSamplingExtraCfg(extraCfg luraconfig.ExtraConfig) (*SamplingOpts, error) {
+	cfg := new(SamplingOpts)
+	if err := decodeNamespace(extraCfg, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, cfg.Validate()
+}
+
+func decodeNamespace(extraCfg luraconfig.ExtraConfig, v interface{}) error {
+	tmp, ok := extraCfg[Namespace]
+	if !ok {
+		return ErrNoConfig
+	}
+
+	buf := new(bytes.Buffer)
+	if err := json.NewEncoder(buf).Encode(tmp); err != nil {
+		return err
+	}
+	return json.NewDecoder(buf).Decode(v)
+}
@@ -0,0 +1,40 @@
+package config
+
+import (
+	"fmt"
+)
+
+// Names of the supported propagators, to be used in the
+// "propagators" lists.
+const (
+	PropagatorTraceContext = "tracecontext"
+	PropagatorBaggage      = "baggage"
+	PropagatorB3           = "b3"
+	PropagatorB3Multi      = "b3multi"
+	PropagatorJaeger       = "jaeger"
+	PropagatorXRay         = "xray"
+	PropagatorOTTrace      = "ottrace"
+	PropagatorNone         = "none"
+)
+
+var validPropagators = map[string]bool{
+	PropagatorTraceContext: true,
+	PropagatorBaggage:      true,
+	PropagatorB3:           true,
+	PropagatorB3Multi:      true,
+	PropagatorJaeger:       true,
+	PropagatorXRay:         true,
+	PropagatorOTTrace:      true,
+	PropagatorNone:         true,
+}
+
+// ValidatePropagators checks that all the propagator names
+// in a list are supported.
+func ValidatePropagators(names []string) error {
+	for _, n := range names {
+		if !validPropagators[n] {
+			return fmt.Errorf("unknown propagator %q", n)
+		}
+	}
+	return nil
+}
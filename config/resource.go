@@ -0,0 +1,56 @@
+package config
+
+import (
+	"fmt"
+)
+
+// Resource detectors that can be enabled.
+const (
+	DetectorHost       = "host"
+	DetectorOS         = "os"
+	DetectorProcess    = "process"
+	DetectorContainer  = "container"
+	DetectorKubernetes = "kubernetes"
+)
+
+// ResourceOpts defines the attributes that describe the KrakenD
+// instance (the OpenTelemetry "resource"), shared by all the metrics,
+// traces and logs.
+//
+// Detectors lists the information to be detected: "host", "os",
+// "process", "container" (the container id, when running under
+// a cgroup) and "kubernetes", that reads the K8S_POD_NAME, K8S_POD_UID,
+// K8S_NAMESPACE_NAME, K8S_NODE_NAME, K8S_CONTAINER_NAME and
+// K8S_DEPLOYMENT_NAME environment variables (to be set with
+// the downward API).
+//
+// ResourceAttributes are added as is, and FromEnv reads the attributes
+// from the standard OTEL_RESOURCE_ATTRIBUTES environment variable.
+//
+// When the same attribute is set by different sources, the static
+// attributes take precedence over the environment, and this one over
+// the detected values. The service name, version and deployment
+// environment always come from the main configuration.
+type ResourceOpts struct {
+	Detectors          []string   `json:"detectors"`
+	ResourceAttributes Attributes `json:"resource_attributes"`
+	FromEnv            bool       `json:"from_env"`
+}
+
+// Validate checks that the detectors are known.
+func (r *ResourceOpts) Validate() error {
+	if r == nil {
+		return nil
+	}
+	for _, d := range r.Detectors {
+		switch d {
+		case DetectorHost, DetectorOS, DetectorProcess, DetectorContainer, DetectorKubernetes:
+		default:
+			return fmt.Errorf("unknown resource detector %q", d)
+		}
+	}
+	if _, err := r.ResourceAttributes.ToMap(); err != nil {
+		return fmt.Errorf("bad resource_attributes: %s", err.Error())
+	}
+	return nil
+}
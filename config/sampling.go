@@ -0,0 +1,169 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// TailSamplingOpts enables the tail based sampling: the spans of each
+// trace are kept in memory until its local root span (usually the one
+// for the server side of the request) ends, and the full trace is only
+// exported if any of the rules matches:
+//   - Errors: any of the spans has an error status.
+//   - LatencyThreshold: the root span lasted at least that duration.
+//   - Routes: any of the spans has one of the routes as "http.route"
+//     (as written in the endpoint definition, like "/payments/:id").
+//   - SampleRate: the probabilistic fallback for the rest of traces.
+//
+// The spans dropped by the head sampler never reach the tail sampling,
+// so "trace_sample_rate" should be left to 1 when using it.
+//
+// The memory used is limited by MaxTraces (the number of traces waiting
+// for a decision, 10000 by default) and MaxSpansPerTrace (1000 by
+// default), and the traces without a decision after DecisionWait ("30s"
+// by default) are dropped: the number of traces and spans dropped because
+// of those limits is reported with the
+// "krakend.otel.tail_sampling.evictions" metric.
+type TailSamplingOpts struct {
+	Errors           bool     `json:"errors"`
+	LatencyThreshold string   `json:"latency_threshold"`
+	Routes           []string `json:"routes"`
+	SampleRate       float64  `json:"sample_rate"`
+	MaxTraces        int      `json:"max_traces"`
+	MaxSpansPerTrace int      `json:"max_spans_per_trace"`
+	DecisionWait     string   `json:"decision_wait"`
+}
+
+// Validate checks the rate, durations and limits of the options.
+func (o *TailSamplingOpts) Validate() error {
+	if o == nil {
+		return nil
+	}
+	if o.SampleRate < 0 || o.SampleRate > 1 {
+		return fmt.Errorf("tail_sampling sample_rate must be between 0 and 1, got %f", o.SampleRate)
+	}
+	if o.MaxTraces < 0 || o.MaxSpansPerTrace < 0 {
+		return fmt.Errorf("tail_sampling limits cannot be negative")
+	}
+	if _, err := ParseDuration(o.LatencyThreshold, 0); err != nil {
+		return fmt.Errorf("bad tail_sampling latency_threshold: %s", err.Error())
+	}
+	if _, err := ParseDuration(o.DecisionWait, 0); err != nil {
+		return fmt.Errorf("bad tail_sampling decision_wait: %s", err.Error())
+	}
+	return nil
+}
+
+// Types of sampler for the traces.
+const (
+	// SamplerTypeRatio samples a ratio of the traces, set
+	// with "trace_sample_rate" (the default).
+	SamplerTypeRatio = "ratio"
+	// SamplerTypeRateLimited samples up to a number of
+	// traces per second.
+	SamplerTypeRateLimited = "rate_limited"
+	// SamplerTypeAlwaysOff does not sample the traces.
+	SamplerTypeAlwaysOff = "always_off"
+)
+
+// SamplerOpts selects how the sampling decision is taken for
+// the traces that start in the service:
+//   - "ratio": the default, using the "trace_sample_rate".
+//   - "rate_limited": samples up to TracesPerSecond, using a token
+//     bucket that allows bursts of up to a second of traces.
+//   - "always_off": no trace is sampled (unless its sampling is
+//     forced, or it follows the decision of its parent).
+//
+// ParentBased (true by default) makes the spans with a remote parent
+// follow its sampling decision, instead of taking a new one. The
+// spans with a local parent always follow its decision.
+type SamplerOpts struct {
+	Type            string  `json:"type"`
+	TracesPerSecond float64 `json:"traces_per_second"`
+	ParentBased     *bool   `json:"parent_based"`
+}
+
+// Validate checks the type of sampler and its settings.
+func (o *SamplerOpts) Validate() error {
+	if o == nil {
+		return nil
+	}
+	switch o.Type {
+	case "", SamplerTypeRatio, SamplerTypeAlwaysOff:
+	case SamplerTypeRateLimited:
+		if o.TracesPerSecond <= 0 {
+			return fmt.Errorf("sampler traces_per_second must be greater than 0, got %f", o.TracesPerSecond)
+		}
+	default:
+		return fmt.Errorf("unknown sampler type %q", o.Type)
+	}
+	return nil
+}
+
+// IsParentBased tells if the sampling decision of a remote parent
+// must be followed.
+func (o *SamplerOpts) IsParentBased() bool {
+	return o == nil || o.ParentBased == nil || *o.ParentBased
+}
+
+// ForceSamplingOpts allows to force the sampling of a request, whatever
+// the configured sampler, by sending the Header or the QueryParam. When
+// a Secret is set, its value must match the secret.
+//
+// The forced decision is propagated to the backends with the sampled flag
+// of the trace context and, when Header is set, with the same header
+// holding "1", so the Secret is never sent to the backends. The Header
+// is never reported as a span attribute.
+type ForceSamplingOpts struct {
+	Header     string `json:"header"`
+	QueryParam string `json:"query_param"`
+	Secret     string `json:"secret"`
+}
+
+// Validate checks that there is a way to force the sampling.
+func (o *ForceSamplingOpts) Validate() error {
+	if o == nil {
+		return nil
+	}
+	if o.Header == "" && o.QueryParam == "" {
+		return fmt.Errorf("force_sampling requires a header or a query_param")
+	}
+	return nil
+}
+
+// RemoteSamplingOpts loads the sampling strategies from a document in
+// the format of the Jaeger sampling strategies file, from a local File
+// or a URL, that is polled every PollingInterval ("1m" by default).
+//
+// The strategy of the service (or the default one) applies to the traces
+// that start in the service, with the operations being endpoint routes,
+// like "/users/:id" or "GET /users/:id". The sample rates set at the
+// endpoint level have precedence over the strategies, and the configured
+// sampler is used when there is no strategy available.
+type RemoteSamplingOpts struct {
+	File            string `json:"file"`
+	URL             string `json:"url"`
+	PollingInterval string `json:"polling_interval"`
+}
+
+// Validate checks that there is a single source for the
+// strategies, and the polling interval.
+func (o *RemoteSamplingOpts) Validate() error {
+	if o == nil {
+		return nil
+	}
+	if (o.File == "") == (o.URL == "") {
+		return fmt.Errorf("remote_sampling requires either a file or a url")
+	}
+	if o.URL != "" {
+		u, err := url.Parse(o.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("bad remote_sampling url %q", o.URL)
+		}
+	}
+	d, err := ParseDuration(o.PollingInterval, 0)
+	if err != nil || d < 0 {
+		return fmt.Errorf("bad remote_sampling polling_interval %q", o.PollingInterval)
+	}
+	return nil
+}
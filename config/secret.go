@@ -0,0 +1,57 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	envValuePrefix  = "env:"
+	fileValuePrefix = "file:"
+)
+
+// ResolveValue returns the value to use for a config entry that
+// might reference a secret stored outside the configuration:
+//   - "env:NAME" is replaced by the content of the NAME environment
+//     variable (that must be defined).
+//   - "file:/path/to/file" is replaced by the content of the file,
+//     without the trailing new lines.
+//
+// Any other value is returned as is.
+func ResolveValue(v string) (string, error) {
+	switch {
+	case strings.HasPrefix(v, envValuePrefix):
+		name := strings.TrimPrefix(v, envValuePrefix)
+		val, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s not defined", name)
+		}
+		return val, nil
+	case strings.HasPrefix(v, fileValuePrefix):
+		name := strings.TrimPrefix(v, fileValuePrefix)
+		b, err := os.ReadFile(name)
+		if err != nil {
+			return "", fmt.Errorf("cannot read file %s: %s", name, err.Error())
+		}
+		return strings.TrimRight(string(b), "\r\n"), nil
+	}
+	return v, nil
+}
+
+// ResolveValues returns a copy of the provided map with all its
+// values resolved with [ResolveValue].
+func ResolveValues(m map[string]string) (map[string]string, error) {
+	if len(m) == 0 {
+		return nil, nil
+	}
+	res := make(map[string]string, len(m))
+	for k, v := range m {
+		rv, err := ResolveValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", k, err.Error())
+		}
+		res[k] = rv
+	}
+	return res, nil
+}
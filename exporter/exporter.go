@@ -8,12 +8,16 @@ import (
 	"sync"
 	"time"
 
+	sdklog "go.opentelemetry.io/otel/sdk/log"
 	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 
 	"github.com/krakend/krakend-otel/config"
+	"github.com/krakend/krakend-otel/exporter/file"
 	"github.com/krakend/krakend-otel/exporter/otelcollector"
 	"github.com/krakend/krakend-otel/exporter/prometheus"
+	"github.com/krakend/krakend-otel/exporter/statsd"
+	"github.com/krakend/krakend-otel/exporter/zipkin"
 )
 
 // MetricReader is the interface required in order to
@@ -25,29 +29,54 @@ type MetricReader interface {
 
 // SpanExporter is the interface required in order to
 // export traces.
+//
+// BatchOpts returns the settings for the span processor that
+// sends the spans to the exporter (nil means using the defaults).
 type SpanExporter interface {
 	SpanExporter() sdktrace.SpanExporter
 	TraceDefaultReporting() bool
+	BatchOpts() *config.BatchOpts
+}
+
+// LogExporter is the interface required in order to
+// export logs.
+type LogExporter interface {
+	LogExporter() sdklog.Exporter
+	LogDefaultReporting() bool
 }
 
 var (
 	metricsInstances map[string]MetricReader
 	tracesInstances  map[string]SpanExporter
+	logsInstances    map[string]LogExporter
 	mu               = new(sync.RWMutex)
 )
 
 func CreateOTLPExporters(ctx context.Context, otlpConfs []config.OTLPExporter) (map[string]MetricReader, map[string]SpanExporter, error) {
+	m, s, _, err := CreateOTLPExportersWithLogs(ctx, otlpConfs)
+	return m, s, err
+}
+
+// CreateOTLPExportersWithLogs creates the OTLP exporters, also returning
+// the ones that have logs enabled.
+func CreateOTLPExportersWithLogs(ctx context.Context, otlpConfs []config.OTLPExporter) (map[string]MetricReader,
+	map[string]SpanExporter, map[string]LogExporter, error,
+) {
 	m := make(map[string]MetricReader, len(otlpConfs))
 	s := make(map[string]SpanExporter, len(otlpConfs))
+	l := make(map[string]LogExporter, len(otlpConfs))
 	for idx, ecfg := range otlpConfs {
 		c, err := otelcollector.Exporter(ctx, ecfg)
 		if err != nil {
-			return nil, nil, fmt.Errorf("OTLP Exporter %s (at idx %d) failed: %s", ecfg.Name, idx, err.Error())
+			return nil, nil, nil, fmt.Errorf("OTLP Exporter %s (at idx %d) failed: %s", ecfg.Name, idx, err.Error())
 		}
 		s[ecfg.Name] = c
 		m[ecfg.Name] = c
+		if c.LogExporter() != nil {
+			l[ecfg.Name] = c
+		}
 	}
-	return m, s, nil
+	return m, s, l, nil
 }
 
 func CreatePrometheusExporters(ctx context.Context, promConfs []config.PrometheusExporter) (map[string]MetricReader, error) {
@@ -62,22 +91,138 @@ func CreatePrometheusExporters(ctx context.Context, promConfs []config.Prometheu
 	return m, nil
 }
 
+// CreateStatsDExporters creates the exporters that send the
+// metrics to StatsD agents.
+func CreateStatsDExporters(ctx context.Context, statsdConfs []config.StatsDExporter) (map[string]MetricReader, error) {
+	m := make(map[string]MetricReader, len(statsdConfs))
+	for idx, ecfg := range statsdConfs {
+		c, err := statsd.Exporter(ctx, ecfg)
+		if err != nil {
+			return nil, fmt.Errorf("statsd exporter %s (at idx %d) failed: %s", ecfg.Name, idx, err.Error())
+		}
+		m[ecfg.Name] = c
+	}
+	return m, nil
+}
+
+// CreateFileExporters creates the exporters that write OTLP JSON
+// lines to the standard output or to files.
+func CreateFileExporters(ctx context.Context, fileConfs []config.FileExporter) (map[string]MetricReader, map[string]SpanExporter, error) {
+	m := make(map[string]MetricReader, len(fileConfs))
+	s := make(map[string]SpanExporter, len(fileConfs))
+	for idx, ecfg := range fileConfs {
+		c, err := file.Exporter(ctx, ecfg)
+		if err != nil {
+			return nil, nil, fmt.Errorf("file exporter %s (at idx %d) failed: %s", ecfg.Name, idx, err.Error())
+		}
+		s[ecfg.Name] = c
+		m[ecfg.Name] = c
+	}
+	return m, s, nil
+}
+
+// CreateZipkinExporters creates the exporters that send the
+// traces to Zipkin collectors.
+func CreateZipkinExporters(ctx context.Context, zipkinConfs []config.ZipkinExporter) (map[string]SpanExporter, error) {
+	s := make(map[string]SpanExporter, len(zipkinConfs))
+	for idx, ecfg := range zipkinConfs {
+		c, err := zipkin.Exporter(ctx, ecfg)
+		if err != nil {
+			return nil, fmt.Errorf("zipkin exporter %s (at idx %d) failed: %s", ecfg.Name, idx, err.Error())
+		}
+		s[ecfg.Name] = c
+	}
+	return s, nil
+}
+
+// CreateCustomExporters uses the registered factories to create the
+// exporters for the "custom" configuration entries.
+func CreateCustomExporters(ctx context.Context, customConfs []config.CustomExporter) (map[string]MetricReader, map[string]SpanExporter, error) {
+	m := make(map[string]MetricReader, len(customConfs))
+	s := make(map[string]SpanExporter, len(customConfs))
+	for idx, ecfg := range customConfs {
+		f, ok := factory(ecfg.Type)
+		if !ok {
+			return nil, nil, fmt.Errorf("custom exporter %s (at idx %d) failed: unknown type %s",
+				ecfg.Name, idx, ecfg.Type)
+		}
+		mr, se, err := f(ctx, ecfg.Name, ecfg.Config)
+		if err != nil {
+			return nil, nil, fmt.Errorf("custom exporter %s (at idx %d) failed: %s", ecfg.Name, idx, err.Error())
+		}
+		if mr != nil {
+			m[ecfg.Name] = mr
+		}
+		if se != nil {
+			s[ecfg.Name] = se
+		}
+	}
+	return m, s, nil
+}
+
 // Instances create instances for a given configuration.
 func Instances(ctx context.Context, cfg *config.ConfigData) (map[string]MetricReader, map[string]SpanExporter, error) {
+	m, s, _, err := InstancesWithLogs(ctx, cfg)
+	return m, s, err
+}
+
+// InstancesWithLogs create instances for a given configuration, including
+// the exporters for logs.
+func InstancesWithLogs(ctx context.Context, cfg *config.ConfigData) (map[string]MetricReader,
+	map[string]SpanExporter, map[string]LogExporter, error,
+) {
 	// Create OTLP (OpenTelemetry Line Protocol) exporters
-	m, s, err := CreateOTLPExporters(ctx, cfg.Exporters.OTLP)
+	m, s, l, err := CreateOTLPExportersWithLogs(ctx, cfg.Exporters.OTLP)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 	// Create Prometheus exporters
 	pm, err := CreatePrometheusExporters(ctx, cfg.Exporters.Prometheus)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 	for k, v := range pm {
 		m[k] = v
 	}
-	return m, s, nil
+	// Create StatsD exporters
+	sm, err := CreateStatsDExporters(ctx, cfg.Exporters.StatsD)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	for k, v := range sm {
+		m[k] = v
+	}
+	// Create file exporters
+	fm, fs, err := CreateFileExporters(ctx, cfg.Exporters.File)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	for k, v := range fm {
+		m[k] = v
+	}
+	for k, v := range fs {
+		s[k] = v
+	}
+	// Create Zipkin exporters
+	zs, err := CreateZipkinExporters(ctx, cfg.Exporters.Zipkin)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	for k, v := range zs {
+		s[k] = v
+	}
+	// Create exporters from the registered factories
+	cm, cs, err := CreateCustomExporters(ctx, cfg.Exporters.Custom)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	for k, v := range cm {
+		m[k] = v
+	}
+	for k, v := range cs {
+		s[k] = v
+	}
+	return m, s, l, nil
 }
 
 // SetGlobalExporterInstances sets the provided metric and traces
@@ -95,6 +240,28 @@ func SetGlobalExporterInstances(m map[string]MetricReader, t map[string]SpanExpo
 	mu.Unlock()
 }
 
+// SetGlobalLogExporterInstances sets the provided logs
+// exporters as global defaults.
+func SetGlobalLogExporterInstances(l map[string]LogExporter) {
+	mu.Lock()
+	logsInstances = make(map[string]LogExporter, len(l))
+	for k, v := range l {
+		logsInstances[k] = v
+	}
+	mu.Unlock()
+}
+
+// GetGlobalLogExporterInstances gets the global logs exporters
+func GetGlobalLogExporterInstances() map[string]LogExporter {
+	mu.RLock()
+	l := make(map[string]LogExporter, len(logsInstances))
+	for k, v := range logsInstances {
+		l[k] = v
+	}
+	mu.RUnlock()
+	return l
+}
+
 // GetGlobalExporterInstances gets the global metrics and traces exporters
 func GetGlobalExporterInstances() (map[string]MetricReader, map[string]SpanExporter) {
 	mu.RLock()
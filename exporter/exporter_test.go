@@ -2,7 +2,13 @@ package exporter
 
 import (
 	"context"
+	"encoding/json"
 	"testing"
+	"time"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	sdktracetest "go.opentelemetry.io/otel/sdk/trace/tracetest"
 
 	"github.com/krakend/krakend-otel/config"
 )
@@ -171,3 +177,118 @@ func TestCreateOTLPExporters(t *testing.T) {
 		})
 	}
 }
+
+type customTestExporter struct {
+	Endpoint string `json:"endpoint"`
+}
+
+func (*customTestExporter) MetricReader(_ time.Duration) sdkmetric.Reader {
+	return sdkmetric.NewManualReader()
+}
+
+func (*customTestExporter) MetricDefaultReporting() bool {
+	return true
+}
+
+func (*customTestExporter) SpanExporter() sdktrace.SpanExporter {
+	return sdktracetest.NewInMemoryExporter()
+}
+
+func (*customTestExporter) TraceDefaultReporting() bool {
+	return true
+}
+
+func (*customTestExporter) BatchOpts() *config.BatchOpts {
+	return nil
+}
+
+// unregisterFactory removes a factory registered by a test, so
+// the process wide registry is clean for the next run.
+func unregisterFactory(typeName string) {
+	factoriesMu.Lock()
+	delete(factories, typeName)
+	factoriesMu.Unlock()
+}
+
+func TestInstances_customFactory(t *testing.T) {
+	var decoded []string
+	f := func(_ context.Context, name string, raw json.RawMessage) (MetricReader, SpanExporter, error) {
+		e := new(customTestExporter)
+		if err := json.Unmarshal(raw, e); err != nil {
+			return nil, nil, err
+		}
+		decoded = append(decoded, name+"@"+e.Endpoint)
+		if name == "traces_only" {
+			return nil, e, nil
+		}
+		return e, e, nil
+	}
+	if err := RegisterFactory("test_custom", f); err != nil {
+		t.Errorf("unexpected error registering the factory: %s", err.Error())
+		return
+	}
+	t.Cleanup(func() { unregisterFactory("test_custom") })
+	if err := RegisterFactory("test_custom", f); err == nil {
+		t.Errorf("expected error registering a factory twice")
+	}
+	if err := RegisterFactory("test_nil", nil); err == nil {
+		t.Errorf("expected error registering a nil factory")
+	}
+
+	cfg := new(config.ConfigData)
+	err := json.Unmarshal([]byte(`{
+		"exporters": {
+			"custom": [
+				{"name": "both", "type": "test_custom", "endpoint": "localhost:1234"},
+				{"name": "traces_only", "type": "test_custom", "endpoint": "localhost:5678"}
+			]
+		}
+	}`), cfg)
+	if err != nil {
+		t.Errorf("unexpected error parsing the config: %s", err.Error())
+		return
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("unexpected validation error: %s", err.Error())
+		return
+	}
+
+	m, s, err := Instances(context.Background(), cfg)
+	if err != nil {
+		t.Errorf("unexpected error creating the instances: %s", err.Error())
+		return
+	}
+	if len(decoded) != 2 || decoded[0] != "both@localhost:1234" || decoded[1] != "traces_only@localhost:5678" {
+		t.Errorf("unexpected decoded configs: %v", decoded)
+	}
+	if _, ok := m["both"]; !ok {
+		t.Errorf("missing metric reader for custom exporter")
+	}
+	if _, ok := m["traces_only"]; ok {
+		t.Errorf("unexpected metric reader for traces only exporter")
+	}
+	if len(s) != 2 {
+		t.Errorf("want 2 span exporters, got: %d", len(s))
+	}
+}
+
+func TestInstances_customUnknownType(t *testing.T) {
+	cfg := &config.ConfigData{
+		Exporters: config.Exporters{
+			Custom: []config.CustomExporter{{Name: "unknown", Type: "not_registered"}},
+		},
+	}
+	if _, _, err := Instances(context.Background(), cfg); err == nil {
+		t.Errorf("expected error for unknown exporter type")
+	}
+}
+
+func TestExportersValidate_duplicateCustomName(t *testing.T) {
+	exporters := config.Exporters{
+		OTLP:   []config.OTLPExporter{{Name: "collector"}},
+		Custom: []config.CustomExporter{{Name: "collector", Type: "test_custom"}},
+	}
+	if err := exporters.Validate(); err == nil {
+		t.Errorf("expected error for duplicate exporter names")
+	}
+}
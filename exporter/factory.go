@@ -0,0 +1,44 @@
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// Factory creates the exporters for a "custom" exporter configuration
+// entry. It receives the name of the exporter, and the full JSON object
+// of the entry to decode its own options.
+//
+// It can return a MetricReader, a SpanExporter or both: a nil value
+// means that the exporter does not support that kind of telemetry.
+type Factory func(ctx context.Context, name string, cfg json.RawMessage) (MetricReader, SpanExporter, error)
+
+var (
+	factories   = map[string]Factory{}
+	factoriesMu = new(sync.RWMutex)
+)
+
+// RegisterFactory makes a factory available to create the "custom"
+// exporters with the given type. It must be called before the
+// exporter instances are created (usually from an init function).
+func RegisterFactory(typeName string, f Factory) error {
+	if typeName == "" || f == nil {
+		return fmt.Errorf("cannot register a factory without type or function")
+	}
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	if _, ok := factories[typeName]; ok {
+		return fmt.Errorf("factory for type %s already registered", typeName)
+	}
+	factories[typeName] = f
+	return nil
+}
+
+func factory(typeName string) (Factory, bool) {
+	factoriesMu.RLock()
+	f, ok := factories[typeName]
+	factoriesMu.RUnlock()
+	return f, ok
+}
@@ -0,0 +1,278 @@
+// Package file implements an exporter that writes traces and metrics
+// as OTLP JSON lines to the standard output or to a file.
+package file
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/krakend/krakend-otel/config"
+)
+
+const (
+	tracesPath  = "/v1/traces"
+	metricsPath = "/v1/metrics"
+)
+
+// FileExporter implements the traces and metrics exporter.
+//
+// The span and metric exporters it returns (one for each state that
+// reports to it) share the output, that is closed once all of them
+// have been shut down.
+type FileExporter struct {
+	exporter                    sdktrace.SpanExporter
+	metricExporter              sdkmetric.Exporter
+	metricsDisabledByDefault    bool
+	tracesDisabledByDefault     bool
+	customMetricReportingPeriod time.Duration
+	batchOpts                   *config.BatchOpts
+
+	w    lineWriter
+	mu   sync.Mutex
+	refs int
+}
+
+// SpanExporter implements the interface to export traces.
+func (c *FileExporter) SpanExporter() sdktrace.SpanExporter {
+	c.acquire()
+	return &spanExporter{SpanExporter: c.exporter, owner: c}
+}
+
+func (c *FileExporter) acquire() {
+	c.mu.Lock()
+	c.refs++
+	c.mu.Unlock()
+}
+
+// release shuts down the exporters and closes the output when
+// there are no more users of them.
+func (c *FileExporter) release(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.refs--
+	if c.refs > 0 {
+		return nil
+	}
+	return errors.Join(c.exporter.Shutdown(ctx), c.metricExporter.Shutdown(ctx), c.w.Close())
+}
+
+// spanExporter is a reference to the span exporter of a [FileExporter].
+type spanExporter struct {
+	sdktrace.SpanExporter
+	owner *FileExporter
+	once  sync.Once
+}
+
+func (e *spanExporter) Shutdown(ctx context.Context) error {
+	var err error
+	e.once.Do(func() { err = e.owner.release(ctx) })
+	return err
+}
+
+// metricExporter is a reference to the metric exporter of a [FileExporter].
+type metricExporter struct {
+	sdkmetric.Exporter
+	owner *FileExporter
+	once  sync.Once
+}
+
+func (e *metricExporter) Shutdown(ctx context.Context) error {
+	var err error
+	e.once.Do(func() { err = e.owner.release(ctx) })
+	return err
+}
+
+// BatchOpts returns the span processor settings for the exporter.
+func (c *FileExporter) BatchOpts() *config.BatchOpts {
+	return c.batchOpts
+}
+
+func (c *FileExporter) MetricReader(reportingPeriod time.Duration) sdkmetric.Reader {
+	if c.customMetricReportingPeriod >= time.Second {
+		reportingPeriod = c.customMetricReportingPeriod
+	}
+	c.acquire()
+	return sdkmetric.NewPeriodicReader(&metricExporter{Exporter: c.metricExporter, owner: c},
+		sdkmetric.WithInterval(reportingPeriod))
+}
+
+func (c *FileExporter) MetricDefaultReporting() bool {
+	return !c.metricsDisabledByDefault
+}
+
+func (c *FileExporter) TraceDefaultReporting() bool {
+	return !c.tracesDisabledByDefault
+}
+
+// Exporter creates a file exporter instance.
+//
+// To produce the same OTLP JSON output that a collector would receive,
+// we use the OTLP http exporters with a transport that, instead of
+// sending the request, writes its content as a JSON line.
+func Exporter(ctx context.Context, cfg config.FileExporter) (*FileExporter, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	var w lineWriter
+	if cfg.Path == "" || cfg.Path == "stdout" {
+		w = &streamWriter{w: os.Stdout}
+	} else {
+		rw, err := newRotatingWriter(cfg.Path, int64(cfg.MaxSizeMB)<<20, cfg.MaxBackups)
+		if err != nil {
+			return nil, fmt.Errorf("cannot open file: %s", err.Error())
+		}
+		w = rw
+	}
+
+	client := &http.Client{Transport: &jsonLinesTransport{w: w}}
+	noRetry := otlptracehttp.RetryConfig{Enabled: false}
+
+	traceExp, err := otlptracehttp.New(ctx,
+		otlptracehttp.WithEndpoint("localhost"),
+		otlptracehttp.WithInsecure(),
+		otlptracehttp.WithURLPath(tracesPath),
+		otlptracehttp.WithHTTPClient(client),
+		otlptracehttp.WithRetry(noRetry))
+	if err != nil {
+		return nil, err
+	}
+
+	metricExp, err := otlpmetrichttp.New(ctx,
+		otlpmetrichttp.WithEndpoint("localhost"),
+		otlpmetrichttp.WithInsecure(),
+		otlpmetrichttp.WithURLPath(metricsPath),
+		otlpmetrichttp.WithHTTPClient(client),
+		otlpmetrichttp.WithRetry(otlpmetrichttp.RetryConfig(noRetry)))
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileExporter{
+		exporter:                    traceExp,
+		metricExporter:              metricExp,
+		metricsDisabledByDefault:    cfg.DisableMetrics,
+		tracesDisabledByDefault:     cfg.DisableTraces,
+		customMetricReportingPeriod: time.Duration(cfg.CustomMetricReportingPeriod) * time.Second,
+		batchOpts:                   cfg.Batch,
+		w:                           w,
+	}, nil
+}
+
+// jsonLinesTransport decodes the OTLP protobuf export requests and
+// writes them as OTLP JSON lines.
+type jsonLinesTransport struct {
+	w lineWriter
+}
+
+func (t *jsonLinesTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	var msg proto.Message
+	switch r.URL.Path {
+	case tracesPath:
+		msg = &coltracepb.ExportTraceServiceRequest{}
+	case metricsPath:
+		msg = &colmetricpb.ExportMetricsServiceRequest{}
+	default:
+		return nil, fmt.Errorf("unexpected export path %s", r.URL.Path)
+	}
+	if err := proto.Unmarshal(body, msg); err != nil {
+		return nil, err
+	}
+
+	line, err := otlpJSON(msg)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.w.WriteLine(line); err != nil {
+		return nil, err
+	}
+
+	return &http.Response{
+		Status:     "200 OK",
+		StatusCode: http.StatusOK,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Body:       http.NoBody,
+		Request:    r,
+	}, nil
+}
+
+// otlpJSON encodes the message following the OTLP JSON rules, that
+// differ from the standard protobuf JSON mapping in that enums are
+// encoded as integers, and trace and span ids as hex strings
+// (instead of base64).
+func otlpJSON(msg proto.Message) ([]byte, error) {
+	b, err := protojson.MarshalOptions{UseEnumNumbers: true}.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if err := hexIDs(v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+var idFields = map[string]bool{
+	"traceId":      true,
+	"spanId":       true,
+	"parentSpanId": true,
+}
+
+func hexIDs(v interface{}) error {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, val := range t {
+			if s, ok := val.(string); ok && idFields[k] {
+				id, err := base64.StdEncoding.DecodeString(s)
+				if err != nil {
+					return fmt.Errorf("bad %s: %s", k, err.Error())
+				}
+				t[k] = hex.EncodeToString(id)
+				continue
+			}
+			if err := hexIDs(val); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, val := range t {
+			if err := hexIDs(val); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+package file
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+
+	"github.com/krakend/krakend-otel/config"
+)
+
+func TestExporter_traces(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "telemetry.jsonl")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	exp, err := Exporter(ctx, config.FileExporter{Name: "local", Path: path})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp.SpanExporter()))
+	_, span := tp.Tracer("test").Start(context.Background(), "test-span")
+	traceID := span.SpanContext().TraceID().String()
+	span.End()
+	if err := tp.Shutdown(context.Background()); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+
+	lines := readLines(t, path)
+	if len(lines) != 1 {
+		t.Errorf("expected 1 line, got %d", len(lines))
+		return
+	}
+
+	var got struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []struct {
+					TraceID string `json:"traceId"`
+					Name    string `json:"name"`
+					Kind    int    `json:"kind"`
+				} `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &got); err != nil {
+		t.Errorf("cannot parse line %q: %s", lines[0], err.Error())
+		return
+	}
+	if len(got.ResourceSpans) != 1 || len(got.ResourceSpans[0].ScopeSpans) != 1 ||
+		len(got.ResourceSpans[0].ScopeSpans[0].Spans) != 1 {
+		t.Errorf("expected a single span, got: %s", lines[0])
+		return
+	}
+	s := got.ResourceSpans[0].ScopeSpans[0].Spans[0]
+	if s.Name != "test-span" {
+		t.Errorf("expected span name test-span, got %s", s.Name)
+	}
+	if s.TraceID != traceID {
+		t.Errorf("expected hex trace id %s, got %s", traceID, s.TraceID)
+	}
+	if s.Kind != 1 {
+		t.Errorf("expected numeric internal span kind (1), got %d", s.Kind)
+	}
+}
+
+func TestExporter_metrics(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "telemetry.jsonl")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	exp, err := Exporter(ctx, config.FileExporter{Name: "local", Path: path})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(exp.MetricReader(time.Minute)))
+	counter, err := mp.Meter("test").Int64Counter("test-counter")
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	counter.Add(context.Background(), 3)
+	if err := mp.Shutdown(context.Background()); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+
+	lines := readLines(t, path)
+	if len(lines) != 1 {
+		t.Errorf("expected 1 line, got %d", len(lines))
+		return
+	}
+	if !strings.Contains(lines[0], `"resourceMetrics"`) || !strings.Contains(lines[0], `"test-counter"`) {
+		t.Errorf("cannot find the metric in %s", lines[0])
+	}
+}
+
+func TestExporter_closedOnShutdown(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "telemetry.jsonl")
+	exp, err := Exporter(context.Background(), config.FileExporter{Name: "local", Path: path})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	w, ok := exp.w.(*rotatingWriter)
+	if !ok {
+		t.Errorf("unexpected writer %T", exp.w)
+		return
+	}
+	isOpen := func() bool {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		return w.f != nil
+	}
+	if isOpen() {
+		t.Errorf("the file must not be kept open before being used")
+	}
+
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp.SpanExporter()))
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(exp.MetricReader(time.Minute)))
+	_, span := tp.Tracer("test").Start(context.Background(), "test-span")
+	span.End()
+	if err := tp.Shutdown(context.Background()); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	if !isOpen() {
+		t.Errorf("the file must be kept open while the metrics exporter is in use")
+	}
+	if err := mp.Shutdown(context.Background()); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	if isOpen() {
+		t.Errorf("the file must be closed once all the exporters have been shut down")
+	}
+}
+
+func TestExporter_badConfig(t *testing.T) {
+	_, err := Exporter(context.Background(), config.FileExporter{Name: "stdout", MaxSizeMB: 1})
+	if err == nil {
+		t.Errorf("expected error rotating the standard output")
+	}
+
+	_, err = Exporter(context.Background(), config.FileExporter{
+		Name: "missing_dir",
+		Path: filepath.Join(t.TempDir(), "missing", "telemetry.jsonl"),
+	})
+	if err == nil {
+		t.Errorf("expected error opening a file in a missing dir")
+	}
+}
+
+func TestRotatingWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "telemetry.jsonl")
+	w, err := newRotatingWriter(path, 10, 2)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	defer w.Close()
+
+	for _, l := range []string{"line-1", "line-2", "line-3", "line-4"} {
+		if err := w.WriteLine([]byte(l)); err != nil {
+			t.Errorf("unexpected error: %s", err.Error())
+			return
+		}
+	}
+
+	expected := map[string][]string{
+		path:        {"line-4"},
+		path + ".1": {"line-3"},
+		path + ".2": {"line-2"},
+	}
+	for p, want := range expected {
+		got := readLines(t, p)
+		if len(got) != len(want) || got[0] != want[0] {
+			t.Errorf("%s: expected %v, got %v", p, want, got)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("expected only 2 backups to be kept")
+	}
+}
+
+func TestRotatingWriter_reopenAfterClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "telemetry.jsonl")
+	w, err := newRotatingWriter(path, 0, 0)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	w.WriteLine([]byte("before"))
+	w.Close()
+	if err := w.WriteLine([]byte("after")); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	w.Close()
+
+	got := readLines(t, path)
+	if len(got) != 2 || got[0] != "before" || got[1] != "after" {
+		t.Errorf("unexpected lines: %v", got)
+	}
+}
+
+func readLines(t *testing.T, path string) []string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Errorf("cannot open %s: %s", path, err.Error())
+		return nil
+	}
+	defer f.Close()
+
+	var lines []string
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for sc.Scan() {
+		lines = append(lines, sc.Text())
+	}
+	return lines
+}
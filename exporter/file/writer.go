@@ -0,0 +1,135 @@
+package file
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// lineWriter writes full lines, so concurrent exports (traces and
+// metrics share the same output) are never interleaved.
+type lineWriter interface {
+	WriteLine(b []byte) error
+	Close() error
+}
+
+// streamWriter writes lines to an already open stream, like the
+// standard output, that is never closed.
+type streamWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *streamWriter) WriteLine(b []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.w.Write(append(b, '\n'))
+	return err
+}
+
+func (*streamWriter) Close() error {
+	return nil
+}
+
+// rotatingWriter writes lines to a file, renaming it to "path.1" once
+// it grows over maxSize bytes (when greater than zero). Previous files
+// are shifted ("path.1" to "path.2", and so on), keeping up to
+// maxBackups of them.
+//
+// The file is lazily (re)opened on the next write after being closed,
+// so it is not kept open by an exporter that is not used.
+type rotatingWriter struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	f          *os.File
+	size       int64
+}
+
+func newRotatingWriter(path string, maxSize int64, maxBackups int) (*rotatingWriter, error) {
+	w := &rotatingWriter{
+		path:       path,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+	}
+	// we open the file on creation to report any permission
+	// problem at startup, instead of on the first export.
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, w.Close()
+}
+
+func (w *rotatingWriter) WriteLine(b []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	line := append(b, '\n')
+	if w.f == nil {
+		if err := w.open(); err != nil {
+			return err
+		}
+	}
+	if w.maxSize > 0 && w.size > 0 && w.size+int64(len(line)) > w.maxSize {
+		if err := w.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := w.f.Write(line)
+	w.size += int64(n)
+	return err
+}
+
+func (w *rotatingWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f == nil {
+		return nil
+	}
+	err := w.f.Close()
+	w.f = nil
+	return err
+}
+
+func (w *rotatingWriter) open() error {
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w.f = f
+	w.size = info.Size()
+	return nil
+}
+
+func (w *rotatingWriter) rotate() error {
+	if err := w.f.Close(); err != nil {
+		return err
+	}
+	w.f = nil
+
+	if w.maxBackups > 0 {
+		for i := w.maxBackups - 1; i > 0; i-- {
+			err := os.Rename(w.backupName(i), w.backupName(i+1))
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		if err := os.Rename(w.path, w.backupName(1)); err != nil {
+			return err
+		}
+	} else if err := os.Remove(w.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return w.open()
+}
+
+func (w *rotatingWriter) backupName(idx int) string {
+	return fmt.Sprintf("%s.%d", w.path, idx)
+}
@@ -0,0 +1,94 @@
+package otelcollector
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+
+	"github.com/krakend/krakend-otel/config"
+)
+
+const (
+	// oauth2EarlyExpiry is the time before a token expires, when
+	// we start requesting a new one.
+	oauth2EarlyExpiry = 30 * time.Second
+	// oauth2RequestTimeout is the max time to wait for the token
+	// endpoint to answer.
+	oauth2RequestTimeout = 10 * time.Second
+)
+
+// errOAuth2Plaintext is returned when the token would be sent to a
+// collector without TLS, and the insecure option is not set.
+var errOAuth2Plaintext = errors.New("oauth2 requires a tls connection to the collector, unless insecure is set")
+
+// oauth2TokenSource creates a token source that uses the client credentials
+// flow to fetch tokens, that are cached and refreshed before they expire.
+func oauth2TokenSource(opts *config.OAuth2Opts) (oauth2.TokenSource, error) {
+	if opts.TokenURL == "" {
+		return nil, errors.New("missing oauth2 token_url")
+	}
+	secret, err := config.ResolveValue(opts.ClientSecret)
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve oauth2 client_secret: %s", err.Error())
+	}
+	cc := &clientcredentials.Config{
+		ClientID:     opts.ClientID,
+		ClientSecret: secret,
+		TokenURL:     opts.TokenURL,
+		Scopes:       opts.Scopes,
+	}
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient,
+		&http.Client{Timeout: oauth2RequestTimeout})
+	fetch := tokenSourceFn(func() (*oauth2.Token, error) {
+		return cc.Token(ctx)
+	})
+	return oauth2.ReuseTokenSourceWithExpiry(nil, fetch, oauth2EarlyExpiry), nil
+}
+
+// tokenSourceFn adapts a function to the oauth2.TokenSource interface
+type tokenSourceFn func() (*oauth2.Token, error)
+
+func (f tokenSourceFn) Token() (*oauth2.Token, error) {
+	return f()
+}
+
+// oauth2PerRPCCredentials attaches the token to each gRPC request.
+type oauth2PerRPCCredentials struct {
+	source     oauth2.TokenSource
+	requireTLS bool
+}
+
+func (c *oauth2PerRPCCredentials) GetRequestMetadata(_ context.Context, _ ...string) (map[string]string, error) {
+	tok, err := c.source.Token()
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{
+		"authorization": tok.Type() + " " + tok.AccessToken,
+	}, nil
+}
+
+func (c *oauth2PerRPCCredentials) RequireTransportSecurity() bool {
+	return c.requireTLS
+}
+
+// oauth2HTTPClient creates an http client that adds the token to all
+// the requests.
+func oauth2HTTPClient(source oauth2.TokenSource, tlsCfg *tls.Config) *http.Client {
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	if tlsCfg != nil {
+		base.TLSClientConfig = tlsCfg
+	}
+	return &http.Client{
+		Transport: &oauth2.Transport{
+			Source: source,
+			Base:   base,
+		},
+	}
+}
@@ -3,18 +3,24 @@ package otelcollector
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"net/url"
 	"strings"
 	"time"
 
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
 	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
 	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 
 	"github.com/krakend/krakend-otel/config"
 )
@@ -23,9 +29,11 @@ import (
 type OtelCollector struct {
 	exporter                    sdktrace.SpanExporter
 	metricExporter              sdkmetric.Exporter
+	logExporter                 sdklog.Exporter
 	metricsDisabledByDefault    bool
 	tracesDisabledByDefault     bool
 	customMetricReportingPeriod time.Duration
+	batchOpts                   *config.BatchOpts
 }
 
 // SpanExporter implements the interface to export traces.
@@ -33,6 +41,11 @@ func (c *OtelCollector) SpanExporter() sdktrace.SpanExporter {
 	return c.exporter
 }
 
+// BatchOpts returns the span processor settings for the exporter.
+func (c *OtelCollector) BatchOpts() *config.BatchOpts {
+	return c.batchOpts
+}
+
 func (c *OtelCollector) MetricReader(reportingPeriod time.Duration) sdkmetric.Reader {
 	if c.customMetricReportingPeriod >= time.Second {
 		reportingPeriod = c.customMetricReportingPeriod
@@ -49,11 +62,47 @@ func (c *OtelCollector) TraceDefaultReporting() bool {
 	return !c.tracesDisabledByDefault
 }
 
+// LogExporter returns the logs exporter, that is only created
+// when logs are enabled for the collector.
+func (c *OtelCollector) LogExporter() sdklog.Exporter {
+	return c.logExporter
+}
+
+func (c *OtelCollector) LogDefaultReporting() bool {
+	return c.logExporter != nil
+}
+
+// collectorEndpoint returns the "host:port" endpoint of the collector, and
+// if the connection to it must be secured with TLS: the "https://" scheme,
+// or setting any of the TLS options, enables it, while having no scheme or
+// the "http://" one uses a plain text connection.
+func collectorEndpoint(cfg config.OTLPExporter) (string, bool, error) {
+	endpoint := fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
+	secure := cfg.TLS != nil
+	if strings.HasPrefix(endpoint, "http://") || strings.HasPrefix(endpoint, "https://") {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return "", false, err
+		}
+		switch u.Scheme {
+		case "http":
+			if secure {
+				return "", false, errors.New("tls options cannot be used with an http:// host")
+			}
+		case "https":
+			secure = true
+		}
+		endpoint = u.Host
+	}
+	return endpoint, secure, nil
+}
+
 func httpExporterWithOptions(ctx context.Context, cfg config.OTLPExporter,
 	options []interface{},
 ) (*OtelCollector, error) {
 	tOpts := make([]otlptracehttp.Option, 0, len(options)+1)
 	mOpts := make([]otlpmetrichttp.Option, 0, len(options)+1)
+	lOpts := make([]otlploghttp.Option, 0, len(options)+1)
 	for _, iopt := range options {
 		if to, ok := iopt.(otlptracehttp.Option); ok {
 			tOpts = append(tOpts, to)
@@ -61,25 +110,94 @@ func httpExporterWithOptions(ctx context.Context, cfg config.OTLPExporter,
 		if mo, ok := iopt.(otlpmetrichttp.Option); ok {
 			mOpts = append(mOpts, mo)
 		}
+		if lo, ok := iopt.(otlploghttp.Option); ok {
+			lOpts = append(lOpts, lo)
+		}
 	}
 
-	endpoint := fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
-	if strings.HasPrefix(endpoint, "http://") || strings.HasPrefix(endpoint, "https://") {
-		u, err := url.Parse(endpoint)
+	endpoint, secure, err := collectorEndpoint(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var tlsCfg *tls.Config
+	if secure {
+		tlsCfg, err = tlsConfig(cfg.TLS)
 		if err != nil {
 			return nil, err
 		}
-		switch u.Scheme {
-		case "http":
-			tOpts = append(tOpts, otlptracehttp.WithInsecure())
-			mOpts = append(mOpts, otlpmetrichttp.WithInsecure())
-			endpoint = u.Host
-		case "https":
-			endpoint = u.Host
+		if tlsCfg != nil {
+			tOpts = append(tOpts, otlptracehttp.WithTLSClientConfig(tlsCfg))
+			mOpts = append(mOpts, otlpmetrichttp.WithTLSClientConfig(tlsCfg))
+			lOpts = append(lOpts, otlploghttp.WithTLSClientConfig(tlsCfg))
 		}
 	} else {
 		tOpts = append(tOpts, otlptracehttp.WithInsecure())
 		mOpts = append(mOpts, otlpmetrichttp.WithInsecure())
+		lOpts = append(lOpts, otlploghttp.WithInsecure())
+	}
+
+	if cfg.Auth != nil && cfg.Auth.OAuth2 != nil {
+		if !secure && !cfg.Auth.OAuth2.Insecure {
+			return nil, errOAuth2Plaintext
+		}
+		ts, err := oauth2TokenSource(cfg.Auth.OAuth2)
+		if err != nil {
+			return nil, err
+		}
+		// the provided client takes precedence over the TLS options,
+		// so we set them in its transport:
+		client := oauth2HTTPClient(ts, tlsCfg)
+		tOpts = append(tOpts, otlptracehttp.WithHTTPClient(client))
+		mOpts = append(mOpts, otlpmetrichttp.WithHTTPClient(client))
+		lOpts = append(lOpts, otlploghttp.WithHTTPClient(client))
+	}
+	headers, err := config.ResolveValues(cfg.Headers)
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve headers: %s", err.Error())
+	}
+	if len(headers) > 0 {
+		tOpts = append(tOpts, otlptracehttp.WithHeaders(headers))
+		mOpts = append(mOpts, otlpmetrichttp.WithHeaders(headers))
+		lOpts = append(lOpts, otlploghttp.WithHeaders(headers))
+	}
+	settings, err := newExportSettings(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if settings.gzip {
+		tOpts = append(tOpts, otlptracehttp.WithCompression(otlptracehttp.GzipCompression))
+		mOpts = append(mOpts, otlpmetrichttp.WithCompression(otlpmetrichttp.GzipCompression))
+		lOpts = append(lOpts, otlploghttp.WithCompression(otlploghttp.GzipCompression))
+	}
+	if settings.timeout > 0 {
+		tOpts = append(tOpts, otlptracehttp.WithTimeout(settings.timeout))
+		mOpts = append(mOpts, otlpmetrichttp.WithTimeout(settings.timeout))
+		lOpts = append(lOpts, otlploghttp.WithTimeout(settings.timeout))
+	}
+	if r := settings.retry; r != nil {
+		tOpts = append(tOpts, otlptracehttp.WithRetry(otlptracehttp.RetryConfig{
+			Enabled:         r.enabled,
+			InitialInterval: r.initialInterval,
+			MaxInterval:     r.maxInterval,
+			MaxElapsedTime:  r.maxElapsedTime,
+		}))
+		mOpts = append(mOpts, otlpmetrichttp.WithRetry(otlpmetrichttp.RetryConfig{
+			Enabled:         r.enabled,
+			InitialInterval: r.initialInterval,
+			MaxInterval:     r.maxInterval,
+			MaxElapsedTime:  r.maxElapsedTime,
+		}))
+		lOpts = append(lOpts, otlploghttp.WithRetry(otlploghttp.RetryConfig{
+			Enabled:         r.enabled,
+			InitialInterval: r.initialInterval,
+			MaxInterval:     r.maxInterval,
+			MaxElapsedTime:  r.maxElapsedTime,
+		}))
+	}
+	if settings.tracesPath != "" {
+		tOpts = append(tOpts, otlptracehttp.WithURLPath(settings.tracesPath))
+		mOpts = append(mOpts, otlpmetrichttp.WithURLPath(settings.metricsPath))
+		lOpts = append(lOpts, otlploghttp.WithURLPath(settings.logsPath))
 	}
 	tOpts = append(tOpts, otlptracehttp.WithEndpoint(endpoint))
 
@@ -94,12 +212,23 @@ func httpExporterWithOptions(ctx context.Context, cfg config.OTLPExporter,
 		return nil, errors.New("cannot create http metric exporter:" + err.Error())
 	}
 
+	var logExporter sdklog.Exporter
+	if cfg.EnableLogs {
+		lOpts = append(lOpts, otlploghttp.WithEndpoint(endpoint))
+		logExporter, err = otlploghttp.New(ctx, lOpts...)
+		if err != nil {
+			return nil, errors.New("cannot create http log exporter:" + err.Error())
+		}
+	}
+
 	return &OtelCollector{
 		exporter:                    exporter,
 		metricExporter:              metricExporter,
+		logExporter:                 logExporter,
 		metricsDisabledByDefault:    cfg.DisableMetrics,
 		tracesDisabledByDefault:     cfg.DisableTraces,
 		customMetricReportingPeriod: time.Duration(cfg.CustomMetricReportingPeriod) * time.Second,
+		batchOpts:                   cfg.Batch,
 	}, nil
 }
 
@@ -108,6 +237,7 @@ func grpcExporterWithOptions(ctx context.Context, cfg config.OTLPExporter,
 ) (*OtelCollector, error) {
 	tOpts := make([]otlptracegrpc.Option, 0, len(options)+1)
 	mOpts := make([]otlpmetricgrpc.Option, 0, len(options)+1)
+	lOpts := make([]otlploggrpc.Option, 0, len(options)+1)
 	for _, iopt := range options {
 		if to, ok := iopt.(otlptracegrpc.Option); ok {
 			tOpts = append(tOpts, to)
@@ -115,25 +245,91 @@ func grpcExporterWithOptions(ctx context.Context, cfg config.OTLPExporter,
 		if mo, ok := iopt.(otlpmetricgrpc.Option); ok {
 			mOpts = append(mOpts, mo)
 		}
+		if lo, ok := iopt.(otlploggrpc.Option); ok {
+			lOpts = append(lOpts, lo)
+		}
 	}
 
-	endpoint := fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
-	if strings.HasPrefix(endpoint, "http://") || strings.HasPrefix(endpoint, "https://") {
-		u, err := url.Parse(endpoint)
+	endpoint, secure, err := collectorEndpoint(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if secure {
+		tlsCfg, err := tlsConfig(cfg.TLS)
 		if err != nil {
 			return nil, err
 		}
-		switch u.Scheme {
-		case "http":
-			tOpts = append(tOpts, otlptracegrpc.WithInsecure())
-			mOpts = append(mOpts, otlpmetricgrpc.WithInsecure())
-			endpoint = u.Host
-		case "https":
-			endpoint = u.Host
+		if tlsCfg == nil {
+			tlsCfg = &tls.Config{MinVersion: tls.VersionTLS12}
 		}
+		creds := credentials.NewTLS(tlsCfg)
+		tOpts = append(tOpts, otlptracegrpc.WithTLSCredentials(creds))
+		mOpts = append(mOpts, otlpmetricgrpc.WithTLSCredentials(creds))
+		lOpts = append(lOpts, otlploggrpc.WithTLSCredentials(creds))
 	} else {
 		tOpts = append(tOpts, otlptracegrpc.WithInsecure())
 		mOpts = append(mOpts, otlpmetricgrpc.WithInsecure())
+		lOpts = append(lOpts, otlploggrpc.WithInsecure())
+	}
+
+	if cfg.Auth != nil && cfg.Auth.OAuth2 != nil {
+		if !secure && !cfg.Auth.OAuth2.Insecure {
+			return nil, errOAuth2Plaintext
+		}
+		ts, err := oauth2TokenSource(cfg.Auth.OAuth2)
+		if err != nil {
+			return nil, err
+		}
+		rpcCreds := grpc.WithPerRPCCredentials(&oauth2PerRPCCredentials{
+			source:     ts,
+			requireTLS: secure,
+		})
+		tOpts = append(tOpts, otlptracegrpc.WithDialOption(rpcCreds))
+		mOpts = append(mOpts, otlpmetricgrpc.WithDialOption(rpcCreds))
+		lOpts = append(lOpts, otlploggrpc.WithDialOption(rpcCreds))
+	}
+	headers, err := config.ResolveValues(cfg.Headers)
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve headers: %s", err.Error())
+	}
+	if len(headers) > 0 {
+		tOpts = append(tOpts, otlptracegrpc.WithHeaders(headers))
+		mOpts = append(mOpts, otlpmetricgrpc.WithHeaders(headers))
+		lOpts = append(lOpts, otlploggrpc.WithHeaders(headers))
+	}
+	settings, err := newExportSettings(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if settings.gzip {
+		tOpts = append(tOpts, otlptracegrpc.WithCompressor("gzip"))
+		mOpts = append(mOpts, otlpmetricgrpc.WithCompressor("gzip"))
+		lOpts = append(lOpts, otlploggrpc.WithCompressor("gzip"))
+	}
+	if settings.timeout > 0 {
+		tOpts = append(tOpts, otlptracegrpc.WithTimeout(settings.timeout))
+		mOpts = append(mOpts, otlpmetricgrpc.WithTimeout(settings.timeout))
+		lOpts = append(lOpts, otlploggrpc.WithTimeout(settings.timeout))
+	}
+	if r := settings.retry; r != nil {
+		tOpts = append(tOpts, otlptracegrpc.WithRetry(otlptracegrpc.RetryConfig{
+			Enabled:         r.enabled,
+			InitialInterval: r.initialInterval,
+			MaxInterval:     r.maxInterval,
+			MaxElapsedTime:  r.maxElapsedTime,
+		}))
+		mOpts = append(mOpts, otlpmetricgrpc.WithRetry(otlpmetricgrpc.RetryConfig{
+			Enabled:         r.enabled,
+			InitialInterval: r.initialInterval,
+			MaxInterval:     r.maxInterval,
+			MaxElapsedTime:  r.maxElapsedTime,
+		}))
+		lOpts = append(lOpts, otlploggrpc.WithRetry(otlploggrpc.RetryConfig{
+			Enabled:         r.enabled,
+			InitialInterval: r.initialInterval,
+			MaxInterval:     r.maxInterval,
+			MaxElapsedTime:  r.maxElapsedTime,
+		}))
 	}
 	tOpts = append(tOpts, otlptracegrpc.WithEndpoint(endpoint))
 
@@ -147,12 +343,23 @@ func grpcExporterWithOptions(ctx context.Context, cfg config.OTLPExporter,
 		return nil, errors.New("cannot create grpc metric exporter")
 	}
 
+	var logExporter sdklog.Exporter
+	if cfg.EnableLogs {
+		lOpts = append(lOpts, otlploggrpc.WithEndpoint(endpoint))
+		logExporter, err = otlploggrpc.New(ctx, lOpts...)
+		if err != nil {
+			return nil, errors.New("cannot create grpc log exporter")
+		}
+	}
+
 	return &OtelCollector{
 		exporter:                    exporter,
 		metricExporter:              metricExporter,
+		logExporter:                 logExporter,
 		metricsDisabledByDefault:    cfg.DisableMetrics,
 		tracesDisabledByDefault:     cfg.DisableTraces,
 		customMetricReportingPeriod: time.Duration(cfg.CustomMetricReportingPeriod) * time.Second,
+		batchOpts:                   cfg.Batch,
 	}, nil
 }
 
@@ -171,8 +378,9 @@ func ExporterWithOptions(ctx context.Context, cfg config.OTLPExporter, options [
 }
 
 // Exporter creates an Open Telemetry exporter instance.
+//
+// Unless the host uses the "https://" scheme, or TLS options are provided,
+// the connection to the collector is not encrypted.
 func Exporter(ctx context.Context, cfg config.OTLPExporter) (*OtelCollector, error) {
-	// by default, grpc conections have TLS enabled:
-	options := []interface{}{otlptracegrpc.WithInsecure(), otlpmetricgrpc.WithInsecure()}
-	return ExporterWithOptions(ctx, cfg, options)
+	return ExporterWithOptions(ctx, cfg, nil)
 }
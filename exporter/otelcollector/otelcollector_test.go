@@ -0,0 +1,839 @@
+package otelcollector
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdktracetest "go.opentelemetry.io/otel/sdk/trace/tracetest"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/krakend/krakend-otel/config"
+)
+
+func TestExporter_httpTLS(t *testing.T) {
+	pki := newTestPKI(t)
+	testCases := []struct {
+		name       string
+		requireMTL bool
+		tlsOpts    *config.TLSOpts
+		wantErr    bool
+	}{
+		{
+			name:    "custom ca",
+			tlsOpts: &config.TLSOpts{CAFile: pki.caFile},
+		},
+		{
+			name:    "unknown ca",
+			tlsOpts: &config.TLSOpts{},
+			wantErr: true,
+		},
+		{
+			name:    "skip verify",
+			tlsOpts: &config.TLSOpts{InsecureSkipVerify: true},
+		},
+		{
+			name:    "server name",
+			tlsOpts: &config.TLSOpts{CAFile: pki.caFile, ServerName: "collector.test"},
+		},
+		{
+			name:    "wrong server name",
+			tlsOpts: &config.TLSOpts{CAFile: pki.caFile, ServerName: "other.test"},
+			wantErr: true,
+		},
+		{
+			name:       "mtls",
+			requireMTL: true,
+			tlsOpts: &config.TLSOpts{
+				CAFile:   pki.caFile,
+				CertFile: pki.clientCertFile,
+				KeyFile:  pki.clientKeyFile,
+			},
+		},
+		{
+			name:       "mtls without client certificate",
+			requireMTL: true,
+			tlsOpts:    &config.TLSOpts{CAFile: pki.caFile},
+			wantErr:    true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			collector := newHTTPCollector(t, pki.serverTLSConfig(tc.requireMTL))
+			host, port := collector.hostPort(t)
+			c, err := Exporter(context.Background(), config.OTLPExporter{
+				Name:    "tls",
+				Host:    "https://" + host,
+				Port:    port,
+				UseHTTP: true,
+				TLS:     tc.tlsOpts,
+			})
+			if err != nil {
+				t.Errorf("unexpected error creating the exporter: %s", err.Error())
+				return
+			}
+			err = exportTestSpan(c)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("expected export error")
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("unexpected export error: %s", err.Error())
+				return
+			}
+			if got := collector.numRequests("/v1/traces"); got != 1 {
+				t.Errorf("want 1 traces request, got: %d", got)
+			}
+		})
+	}
+}
+
+func TestExporter_grpcTLS(t *testing.T) {
+	pki := newTestPKI(t)
+	testCases := []struct {
+		name       string
+		requireMTL bool
+		tlsOpts    *config.TLSOpts
+		wantErr    bool
+	}{
+		{
+			name:    "custom ca",
+			tlsOpts: &config.TLSOpts{CAFile: pki.caFile},
+		},
+		{
+			name:    "unknown ca",
+			tlsOpts: &config.TLSOpts{},
+			wantErr: true,
+		},
+		{
+			name:       "mtls",
+			requireMTL: true,
+			tlsOpts: &config.TLSOpts{
+				CAFile:     pki.caFile,
+				CertFile:   pki.clientCertFile,
+				KeyFile:    pki.clientKeyFile,
+				ServerName: "collector.test",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			collector := newGRPCCollector(t, credentials.NewTLS(pki.serverTLSConfig(tc.requireMTL)))
+			host, port := collector.hostPort(t)
+			c, err := Exporter(context.Background(), config.OTLPExporter{
+				Name: "tls",
+				Host: host,
+				Port: port,
+				TLS:  tc.tlsOpts,
+			})
+			if err != nil {
+				t.Errorf("unexpected error creating the exporter: %s", err.Error())
+				return
+			}
+			err = exportTestSpan(c)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("expected export error")
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("unexpected export error: %s", err.Error())
+				return
+			}
+			if got := collector.numRequests(); got != 1 {
+				t.Errorf("want 1 traces request, got: %d", got)
+			}
+		})
+	}
+}
+
+func TestExporter_tlsWithPlainHTTPHost(t *testing.T) {
+	_, err := Exporter(context.Background(), config.OTLPExporter{
+		Name: "bad",
+		Host: "http://localhost",
+		TLS:  &config.TLSOpts{},
+	})
+	if err == nil {
+		t.Errorf("expected error using tls options with an http:// host")
+	}
+}
+
+func TestExporter_headers(t *testing.T) {
+	t.Setenv("KOTEL_TEST_API_KEY", "env-secret")
+	keyFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(keyFile, []byte("file-secret\n"), 0o600); err != nil {
+		t.Fatalf("cannot write token file: %s", err.Error())
+	}
+	headers := map[string]string{
+		"X-Api-Key":    "env:KOTEL_TEST_API_KEY",
+		"X-Token":      "file:" + keyFile,
+		"X-Plain-Text": "plain",
+	}
+	want := map[string]string{
+		"X-Api-Key":    "env-secret",
+		"X-Token":      "file-secret",
+		"X-Plain-Text": "plain",
+	}
+
+	t.Run("http", func(t *testing.T) {
+		collector := newHTTPCollector(t, nil)
+		host, port := collector.hostPort(t)
+		c, err := Exporter(context.Background(), config.OTLPExporter{
+			Name:    "http_headers",
+			Host:    host,
+			Port:    port,
+			UseHTTP: true,
+			Headers: headers,
+		})
+		if err != nil {
+			t.Errorf("unexpected error creating the exporter: %s", err.Error())
+			return
+		}
+		if err := exportTestSpan(c); err != nil {
+			t.Errorf("unexpected export error: %s", err.Error())
+			return
+		}
+		r := collector.lastRequest()
+		if r == nil {
+			t.Errorf("no request received")
+			return
+		}
+		for k, v := range want {
+			if got := r.Header.Get(k); got != v {
+				t.Errorf("header %s, want: %q, got: %q", k, v, got)
+			}
+		}
+	})
+
+	t.Run("grpc", func(t *testing.T) {
+		collector := newGRPCCollector(t, nil)
+		host, port := collector.hostPort(t)
+		c, err := Exporter(context.Background(), config.OTLPExporter{
+			Name:    "grpc_headers",
+			Host:    host,
+			Port:    port,
+			Headers: headers,
+		})
+		if err != nil {
+			t.Errorf("unexpected error creating the exporter: %s", err.Error())
+			return
+		}
+		if err := exportTestSpan(c); err != nil {
+			t.Errorf("unexpected export error: %s", err.Error())
+			return
+		}
+		md := collector.lastMetadata()
+		for k, v := range want {
+			if got := md.Get(k); len(got) != 1 || got[0] != v {
+				t.Errorf("metadata %s, want: %q, got: %q", k, v, got)
+			}
+		}
+	})
+
+	t.Run("undefined env var", func(t *testing.T) {
+		_, err := Exporter(context.Background(), config.OTLPExporter{
+			Name:    "bad_headers",
+			Headers: map[string]string{"X-Api-Key": "env:KOTEL_TEST_UNDEFINED_VAR"},
+		})
+		if err == nil {
+			t.Errorf("expected error for undefined environment variable")
+		}
+	})
+}
+
+func TestExporter_oauth2(t *testing.T) {
+	testCases := []struct {
+		name       string
+		expiresIn  int
+		wantTokens []string
+	}{
+		{
+			name:       "cached token",
+			expiresIn:  3600,
+			wantTokens: []string{"Bearer token-1", "Bearer token-1"},
+		},
+		{
+			name:       "refresh before expiry",
+			expiresIn:  1,
+			wantTokens: []string{"Bearer token-1", "Bearer token-2"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run("http "+tc.name, func(t *testing.T) {
+			tokenSrv := newTokenServer(t, tc.expiresIn)
+			collector := newHTTPCollector(t, nil)
+			host, port := collector.hostPort(t)
+			c, err := Exporter(context.Background(), config.OTLPExporter{
+				Name:    "http_oauth2",
+				Host:    host,
+				Port:    port,
+				UseHTTP: true,
+				Auth:    &config.AuthOpts{OAuth2: tokenSrv.opts()},
+			})
+			if err != nil {
+				t.Errorf("unexpected error creating the exporter: %s", err.Error())
+				return
+			}
+			for idx, want := range tc.wantTokens {
+				if err := exportTestSpanNoShutdown(c); err != nil {
+					t.Errorf("unexpected export error: %s", err.Error())
+					return
+				}
+				if got := collector.lastRequest().Header.Get("Authorization"); got != want {
+					t.Errorf("export %d, want: %q, got: %q", idx, want, got)
+				}
+			}
+		})
+
+		t.Run("grpc "+tc.name, func(t *testing.T) {
+			tokenSrv := newTokenServer(t, tc.expiresIn)
+			collector := newGRPCCollector(t, nil)
+			host, port := collector.hostPort(t)
+			c, err := Exporter(context.Background(), config.OTLPExporter{
+				Name: "grpc_oauth2",
+				Host: host,
+				Port: port,
+				Auth: &config.AuthOpts{OAuth2: tokenSrv.opts()},
+			})
+			if err != nil {
+				t.Errorf("unexpected error creating the exporter: %s", err.Error())
+				return
+			}
+			for idx, want := range tc.wantTokens {
+				if err := exportTestSpanNoShutdown(c); err != nil {
+					t.Errorf("unexpected export error: %s", err.Error())
+					return
+				}
+				got := collector.lastMetadata().Get("authorization")
+				if len(got) != 1 || got[0] != want {
+					t.Errorf("export %d, want: %q, got: %q", idx, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestExporter_oauth2MissingTokenURL(t *testing.T) {
+	_, err := Exporter(context.Background(), config.OTLPExporter{
+		Name: "bad_oauth2",
+		Auth: &config.AuthOpts{OAuth2: &config.OAuth2Opts{ClientID: "foo"}},
+	})
+	if err == nil {
+		t.Errorf("expected error for missing token url")
+	}
+}
+
+func TestExporter_oauth2Plaintext(t *testing.T) {
+	for _, useHTTP := range []bool{false, true} {
+		_, err := Exporter(context.Background(), config.OTLPExporter{
+			Name:    "plaintext_oauth2",
+			Host:    "http://localhost",
+			UseHTTP: useHTTP,
+			Auth: &config.AuthOpts{OAuth2: &config.OAuth2Opts{
+				TokenURL: "https://auth.example.com/token",
+				ClientID: "foo",
+			}},
+		})
+		if err != errOAuth2Plaintext {
+			t.Errorf("use_http %v: expected the plaintext error, got %v", useHTTP, err)
+		}
+	}
+}
+
+func TestExporter_httpTuning(t *testing.T) {
+	collector := newHTTPCollector(t, nil)
+	host, port := collector.hostPort(t)
+	c, err := Exporter(context.Background(), config.OTLPExporter{
+		Name:        "http_tuning",
+		Host:        host,
+		Port:        port,
+		UseHTTP:     true,
+		Compression: "gzip",
+		Timeout:     "2s",
+		URLPath:     "/collector/otlp",
+	})
+	if err != nil {
+		t.Errorf("unexpected error creating the exporter: %s", err.Error())
+		return
+	}
+	if err := exportTestSpan(c); err != nil {
+		t.Errorf("unexpected export error: %s", err.Error())
+		return
+	}
+	r := collector.lastRequest()
+	if r == nil {
+		t.Errorf("no request received")
+		return
+	}
+	if r.URL.Path != "/collector/otlp/v1/traces" {
+		t.Errorf("unexpected path: %s", r.URL.Path)
+	}
+	if got := r.Header.Get("Content-Encoding"); got != "gzip" {
+		t.Errorf("want gzip content encoding, got: %q", got)
+	}
+}
+
+func TestExporter_grpcTuning(t *testing.T) {
+	collector := newGRPCCollector(t, nil)
+	host, port := collector.hostPort(t)
+	c, err := Exporter(context.Background(), config.OTLPExporter{
+		Name:        "grpc_tuning",
+		Host:        host,
+		Port:        port,
+		Compression: "gzip",
+		Timeout:     "2s",
+		Retry:       &config.RetryOpts{InitialInterval: "100ms"},
+	})
+	if err != nil {
+		t.Errorf("unexpected error creating the exporter: %s", err.Error())
+		return
+	}
+	if err := exportTestSpan(c); err != nil {
+		t.Errorf("unexpected export error: %s", err.Error())
+		return
+	}
+	if got := collector.numRequests(); got != 1 {
+		t.Errorf("want 1 traces request, got: %d", got)
+	}
+}
+
+func TestExporter_retry(t *testing.T) {
+	enabled, disabled := true, false
+	testCases := []struct {
+		name        string
+		retry       *config.RetryOpts
+		minRequests int
+		maxRequests int
+	}{
+		{
+			name:        "disabled",
+			retry:       &config.RetryOpts{Enabled: &disabled},
+			minRequests: 1,
+			maxRequests: 1,
+		},
+		{
+			name: "enabled",
+			retry: &config.RetryOpts{
+				Enabled:         &enabled,
+				InitialInterval: "10ms",
+				MaxInterval:     "20ms",
+				MaxElapsedTime:  "300ms",
+			},
+			minRequests: 2,
+			maxRequests: 100,
+		},
+		{
+			// only tuning the intervals keeps the retry enabled
+			name: "enabled_by_default",
+			retry: &config.RetryOpts{
+				InitialInterval: "10ms",
+				MaxInterval:     "20ms",
+				MaxElapsedTime:  "300ms",
+			},
+			minRequests: 2,
+			maxRequests: 100,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			collector := newHTTPCollector(t, nil)
+			collector.setStatus(http.StatusServiceUnavailable)
+			host, port := collector.hostPort(t)
+			c, err := Exporter(context.Background(), config.OTLPExporter{
+				Name:    "retry",
+				Host:    host,
+				Port:    port,
+				UseHTTP: true,
+				Retry:   tc.retry,
+			})
+			if err != nil {
+				t.Errorf("unexpected error creating the exporter: %s", err.Error())
+				return
+			}
+			if err := exportTestSpan(c); err == nil {
+				t.Errorf("expected export error")
+			}
+			got := collector.numRequests("/v1/traces")
+			if got < tc.minRequests || got > tc.maxRequests {
+				t.Errorf("want between %d and %d requests, got: %d",
+					tc.minRequests, tc.maxRequests, got)
+			}
+		})
+	}
+}
+
+func TestExporter_badTuning(t *testing.T) {
+	testCases := map[string]config.OTLPExporter{
+		"compression": {Name: "bad", Compression: "zstd"},
+		"timeout":     {Name: "bad", Timeout: "ten seconds"},
+		"retry":       {Name: "bad", Retry: &config.RetryOpts{MaxInterval: "1x"}},
+		"grpc path":   {Name: "bad", URLPath: "/foo"},
+	}
+	for name, cfg := range testCases {
+		if _, err := Exporter(context.Background(), cfg); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestExporter_httpLogs(t *testing.T) {
+	collector := newHTTPCollector(t, nil)
+	host, port := collector.hostPort(t)
+
+	c, err := Exporter(context.Background(), config.OTLPExporter{
+		Name:    "no_logs",
+		Host:    host,
+		Port:    port,
+		UseHTTP: true,
+	})
+	if err != nil {
+		t.Errorf("unexpected error creating the exporter: %s", err.Error())
+		return
+	}
+	if c.LogExporter() != nil || c.LogDefaultReporting() {
+		t.Errorf("logs must be disabled unless explicitly enabled")
+	}
+
+	c, err = Exporter(context.Background(), config.OTLPExporter{
+		Name:       "logs",
+		Host:       host,
+		Port:       port,
+		UseHTTP:    true,
+		EnableLogs: true,
+		URLPath:    "/otlp",
+	})
+	if err != nil {
+		t.Errorf("unexpected error creating the exporter: %s", err.Error())
+		return
+	}
+	if c.LogExporter() == nil || !c.LogDefaultReporting() {
+		t.Errorf("expected logs to be enabled")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	var rec sdklog.Record
+	rec.SetBody(log.StringValue("test-record"))
+	if err := c.LogExporter().Export(ctx, []sdklog.Record{rec}); err != nil {
+		t.Errorf("unexpected export error: %s", err.Error())
+		return
+	}
+	c.LogExporter().Shutdown(ctx)
+	if n := collector.numRequests("/otlp/v1/logs"); n != 1 {
+		t.Errorf("expected 1 logs request, got %d", n)
+	}
+}
+
+func exportTestSpan(c *OtelCollector) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	defer c.SpanExporter().Shutdown(ctx)
+	spans := sdktracetest.SpanStubs{{Name: "test-span"}}
+	return c.SpanExporter().ExportSpans(ctx, spans.Snapshots())
+}
+
+func exportTestSpanNoShutdown(c *OtelCollector) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	spans := sdktracetest.SpanStubs{{Name: "test-span"}}
+	return c.SpanExporter().ExportSpans(ctx, spans.Snapshots())
+}
+
+// tokenServer is an OAuth2 token endpoint stand-in, that returns
+// a new token for each client credentials request.
+type tokenServer struct {
+	srv       *httptest.Server
+	mu        sync.Mutex
+	issued    int
+	expiresIn int
+}
+
+func newTokenServer(t *testing.T, expiresIn int) *tokenServer {
+	ts := &tokenServer{expiresIn: expiresIn}
+	ts.srv = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "client_credentials" {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if id, secret, ok := r.BasicAuth(); !ok || id != "client" || secret != "s3cr3t" {
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		ts.mu.Lock()
+		ts.issued++
+		n := ts.issued
+		ts.mu.Unlock()
+		rw.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(rw, `{"access_token":"token-%d","token_type":"Bearer","expires_in":%d}`,
+			n, ts.expiresIn)
+	}))
+	t.Cleanup(ts.srv.Close)
+	return ts
+}
+
+func (ts *tokenServer) opts() *config.OAuth2Opts {
+	return &config.OAuth2Opts{
+		TokenURL:     ts.srv.URL + "/token",
+		ClientID:     "client",
+		ClientSecret: "s3cr3t",
+		Scopes:       []string{"telemetry"},
+		// the test collectors do not use TLS
+		Insecure: true,
+	}
+}
+
+// httpCollector is an OTLP over HTTP collector stand-in that records the
+// received requests.
+type httpCollector struct {
+	srv      *httptest.Server
+	mu       sync.Mutex
+	requests []*http.Request
+	status   int
+}
+
+func newHTTPCollector(t *testing.T, tlsCfg *tls.Config) *httpCollector {
+	c := new(httpCollector)
+	c.srv = httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		c.mu.Lock()
+		c.requests = append(c.requests, r)
+		status := c.status
+		c.mu.Unlock()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		rw.Header().Set("Content-Type", "application/x-protobuf")
+		rw.WriteHeader(status)
+	}))
+	if tlsCfg != nil {
+		c.srv.TLS = tlsCfg
+		c.srv.StartTLS()
+	} else {
+		c.srv.Start()
+	}
+	t.Cleanup(c.srv.Close)
+	return c
+}
+
+func (c *httpCollector) hostPort(t *testing.T) (string, int) {
+	return splitHostPort(t, c.srv.Listener.Addr().String())
+}
+
+func (c *httpCollector) numRequests(path string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for _, r := range c.requests {
+		if r.URL.Path == path {
+			n++
+		}
+	}
+	return n
+}
+
+func (c *httpCollector) setStatus(status int) {
+	c.mu.Lock()
+	c.status = status
+	c.mu.Unlock()
+}
+
+func (c *httpCollector) lastRequest() *http.Request {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.requests) == 0 {
+		return nil
+	}
+	return c.requests[len(c.requests)-1]
+}
+
+// grpcCollector is an OTLP over gRPC traces collector stand-in that
+// records the metadata of the received requests.
+type grpcCollector struct {
+	collectortrace.UnimplementedTraceServiceServer
+
+	lis      net.Listener
+	mu       sync.Mutex
+	metadata []metadata.MD
+}
+
+func newGRPCCollector(t *testing.T, creds credentials.TransportCredentials) *grpcCollector {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %s", err.Error())
+	}
+	var opts []grpc.ServerOption
+	if creds != nil {
+		opts = append(opts, grpc.Creds(creds))
+	}
+	srv := grpc.NewServer(opts...)
+	c := &grpcCollector{lis: lis}
+	collectortrace.RegisterTraceServiceServer(srv, c)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	return c
+}
+
+func (c *grpcCollector) Export(ctx context.Context,
+	_ *collectortrace.ExportTraceServiceRequest,
+) (*collectortrace.ExportTraceServiceResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	c.mu.Lock()
+	c.metadata = append(c.metadata, md)
+	c.mu.Unlock()
+	return &collectortrace.ExportTraceServiceResponse{}, nil
+}
+
+func (c *grpcCollector) hostPort(t *testing.T) (string, int) {
+	return splitHostPort(t, c.lis.Addr().String())
+}
+
+func (c *grpcCollector) numRequests() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.metadata)
+}
+
+func (c *grpcCollector) lastMetadata() metadata.MD {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.metadata) == 0 {
+		return metadata.MD{}
+	}
+	return c.metadata[len(c.metadata)-1]
+}
+
+func splitHostPort(t *testing.T, addr string) (string, int) {
+	host, sPort, err := net.SplitHostPort(addr)
+	if err != nil {
+		t.Fatalf("cannot split address %s: %s", addr, err.Error())
+	}
+	port, err := strconv.Atoi(sPort)
+	if err != nil {
+		t.Fatalf("cannot parse port %s: %s", sPort, err.Error())
+	}
+	return host, port
+}
+
+// testPKI holds a certificate authority, and a server and client
+// certificates signed by it.
+type testPKI struct {
+	caPool     *x509.CertPool
+	serverCert tls.Certificate
+
+	caFile         string
+	clientCertFile string
+	clientKeyFile  string
+}
+
+func (p *testPKI) serverTLSConfig(requireClientCert bool) *tls.Config {
+	cfg := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{p.serverCert},
+	}
+	if requireClientCert {
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		cfg.ClientCAs = p.caPool
+	}
+	return cfg
+}
+
+func newTestPKI(t *testing.T) *testPKI {
+	dir := t.TempDir()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("cannot generate ca key: %s", err.Error())
+	}
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("cannot create ca certificate: %s", err.Error())
+	}
+	caCert, _ := x509.ParseCertificate(caDER)
+	pool := x509.NewCertPool()
+	pool.AddCert(caCert)
+
+	issue := func(serial int64, usage x509.ExtKeyUsage) ([]byte, []byte) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatalf("cannot generate key: %s", err.Error())
+		}
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: "collector.test"},
+			DNSNames:     []string{"collector.test"},
+			IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, caCert, &key.PublicKey, caKey)
+		if err != nil {
+			t.Fatalf("cannot create certificate: %s", err.Error())
+		}
+		keyDER, _ := x509.MarshalECPrivateKey(key)
+		return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+			pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	}
+
+	srvCertPEM, srvKeyPEM := issue(2, x509.ExtKeyUsageServerAuth)
+	srvCert, err := tls.X509KeyPair(srvCertPEM, srvKeyPEM)
+	if err != nil {
+		t.Fatalf("cannot load server certificate: %s", err.Error())
+	}
+	clientCertPEM, clientKeyPEM := issue(3, x509.ExtKeyUsageClientAuth)
+
+	p := &testPKI{
+		caPool:         pool,
+		serverCert:     srvCert,
+		caFile:         filepath.Join(dir, "ca.pem"),
+		clientCertFile: filepath.Join(dir, "client.pem"),
+		clientKeyFile:  filepath.Join(dir, "client-key.pem"),
+	}
+	files := map[string][]byte{
+		p.caFile:         pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}),
+		p.clientCertFile: clientCertPEM,
+		p.clientKeyFile:  clientKeyPEM,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(name, content, 0o600); err != nil {
+			t.Fatalf("cannot write %s: %s", name, err.Error())
+		}
+	}
+	return p
+}
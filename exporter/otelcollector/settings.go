@@ -0,0 +1,59 @@
+package otelcollector
+
+import (
+	"path"
+	"time"
+
+	"github.com/krakend/krakend-otel/config"
+)
+
+// Default values used by the SDK when retry is enabled.
+const (
+	defaultRetryInitialInterval = 5 * time.Second
+	defaultRetryMaxInterval     = 30 * time.Second
+	defaultRetryMaxElapsedTime  = time.Minute
+)
+
+// exportSettings holds the parsed tuning options shared by the
+// traces, metrics and logs exporters.
+type exportSettings struct {
+	gzip        bool
+	timeout     time.Duration
+	retry       *retrySettings
+	tracesPath  string
+	metricsPath string
+	logsPath    string
+}
+
+type retrySettings struct {
+	enabled         bool
+	initialInterval time.Duration
+	maxInterval     time.Duration
+	maxElapsedTime  time.Duration
+}
+
+func newExportSettings(cfg config.OTLPExporter) (*exportSettings, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	s := &exportSettings{
+		gzip: cfg.Compression == "gzip",
+	}
+	// errors have already been checked by the config validation:
+	s.timeout, _ = config.ParseDuration(cfg.Timeout, 0)
+	if cfg.Retry != nil {
+		s.retry = &retrySettings{enabled: cfg.Retry.IsEnabled()}
+		s.retry.initialInterval, _ = config.ParseDuration(cfg.Retry.InitialInterval,
+			defaultRetryInitialInterval)
+		s.retry.maxInterval, _ = config.ParseDuration(cfg.Retry.MaxInterval,
+			defaultRetryMaxInterval)
+		s.retry.maxElapsedTime, _ = config.ParseDuration(cfg.Retry.MaxElapsedTime,
+			defaultRetryMaxElapsedTime)
+	}
+	if cfg.URLPath != "" {
+		s.tracesPath = path.Join("/", cfg.URLPath, "v1/traces")
+		s.metricsPath = path.Join("/", cfg.URLPath, "v1/metrics")
+		s.logsPath = path.Join("/", cfg.URLPath, "v1/logs")
+	}
+	return s, nil
+}
@@ -6,11 +6,15 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	prom "github.com/prometheus/client_golang/prometheus"
 	promcollectors "github.com/prometheus/client_golang/prometheus/collectors"
 	promhttp "github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
 	"go.opentelemetry.io/otel/exporters/prometheus"
 	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 
@@ -22,11 +26,103 @@ type PrometheusCollector struct {
 	registry          *prom.Registry
 	exporter          *prometheus.Exporter
 	disabledByDefault bool
+
+	mu          sync.Mutex
+	readerInUse bool
+	// extraRegistries has the registries for the additional readers
+	extraRegistries []*prom.Registry
 }
 
 // MetricReader implements the interface to exporte metrics.
+//
+// A reader can only be used by a single meter provider, so when more
+// than one provider reports to this exporter (when endpoints or backends
+// select their own exporters) a new reader is created for each of them,
+// and their metrics are merged when gathered.
 func (c *PrometheusCollector) MetricReader(_ time.Duration) sdkmetric.Reader {
-	return c.exporter
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.readerInUse {
+		c.readerInUse = true
+		return c.exporter
+	}
+
+	registry := prom.NewRegistry()
+	exporter, err := prometheus.New(prometheus.WithRegisterer(registry))
+	if err != nil {
+		log.Printf("[SERVICE: kotel] Cannot create an additional Prometheus reader: %v", err)
+		return sdkmetric.NewManualReader()
+	}
+	c.extraRegistries = append(c.extraRegistries, registry)
+	return exporter
+}
+
+// Gather implements the prometheus.Gatherer interface, merging the
+// metrics of all the readers, and skipping the repeated ones (like
+// the target info, that is reported by all of them).
+func (c *PrometheusCollector) Gather() ([]*dto.MetricFamily, error) {
+	c.mu.Lock()
+	gatherers := make([]prom.Gatherer, 0, len(c.extraRegistries)+1)
+	gatherers = append(gatherers, c.registry)
+	for _, r := range c.extraRegistries {
+		gatherers = append(gatherers, r)
+	}
+	c.mu.Unlock()
+
+	var errs prom.MultiError
+	families := map[string]*dto.MetricFamily{}
+	seen := map[string]bool{}
+	for _, g := range gatherers {
+		mfs, err := g.Gather()
+		if err != nil {
+			errs.Append(err)
+		}
+		for _, mf := range mfs {
+			f, ok := families[mf.GetName()]
+			if !ok {
+				f = &dto.MetricFamily{
+					Name: mf.Name,
+					Help: mf.Help,
+					Type: mf.Type,
+					Unit: mf.Unit,
+				}
+				families[mf.GetName()] = f
+			} else if f.GetType() != mf.GetType() {
+				continue
+			}
+			for _, m := range mf.Metric {
+				k := metricKey(mf.GetName(), m.Label)
+				if seen[k] {
+					continue
+				}
+				seen[k] = true
+				f.Metric = append(f.Metric, m)
+			}
+		}
+	}
+
+	names := make([]string, 0, len(families))
+	for k := range families {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	res := make([]*dto.MetricFamily, 0, len(names))
+	for _, n := range names {
+		res = append(res, families[n])
+	}
+	return res, errs.MaybeUnwrap()
+}
+
+func metricKey(name string, labels []*dto.LabelPair) string {
+	var sb strings.Builder
+	sb.WriteString(name)
+	for _, l := range labels {
+		sb.WriteByte(0xff)
+		sb.WriteString(l.GetName())
+		sb.WriteByte('=')
+		sb.WriteString(l.GetValue())
+	}
+	return sb.String()
 }
 
 func (c *PrometheusCollector) MetricDefaultReporting() bool {
@@ -59,31 +155,98 @@ func Exporter(ctx context.Context, cfg config.PrometheusExporter) (*PrometheusCo
 		return nil, err
 	}
 
+	c := &PrometheusCollector{
+		registry:          prometheusRegistry,
+		exporter:          exporter,
+		disabledByDefault: cfg.DisableMetrics,
+	}
+
 	router := http.NewServeMux()
-	router.Handle("/metrics", promhttp.HandlerFor(prometheusRegistry,
-		promhttp.HandlerOpts{}))
-	server := http.Server{
-		Handler:           router,
-		Addr:              fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
-		ReadHeaderTimeout: 3 * time.Second,
+	router.Handle("/metrics", promhttp.HandlerFor(c, promhttp.HandlerOpts{}))
+	serve(ctx, fmt.Sprintf("%s:%d", cfg.Host, cfg.Port), router)
+
+	return c, nil
+}
+
+// sharedServer is a metrics server that can be used by more than one
+// exporter: the last registered handler that is still in use is the one
+// used. This allows to create a new exporter for the same address (when
+// the configuration is reloaded) before the previous one has been shut
+// down.
+type sharedServer struct {
+	server   *http.Server
+	mu       sync.RWMutex
+	handlers []*registeredHandler
+}
+
+type registeredHandler struct {
+	http.Handler
+}
+
+func (s *sharedServer) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	h := s.handlers[len(s.handlers)-1]
+	s.mu.RUnlock()
+	h.ServeHTTP(rw, r)
+}
+
+// remove unregisters the handler, and returns the number of
+// handlers left.
+func (s *sharedServer) remove(h *registeredHandler) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, rh := range s.handlers {
+		if rh == h {
+			s.handlers = append(s.handlers[:i], s.handlers[i+1:]...)
+			break
+		}
 	}
+	return len(s.handlers)
+}
 
-	go func() {
-		if serverErr := server.ListenAndServe(); serverErr != http.ErrServerClosed {
-			log.Printf("[SERVICE: kotel] The Prometheus exporter failed to listen and serve: %v", serverErr)
+var (
+	servers   = map[string]*sharedServer{}
+	serversMu sync.Mutex
+)
+
+// serve registers the handler for the address, starting the server if
+// there is none listening for it. When the context is done, the server
+// is shut down if no other exporter uses it.
+func serve(ctx context.Context, addr string, h http.Handler) {
+	serversMu.Lock()
+	srv, ok := servers[addr]
+	if !ok {
+		srv = &sharedServer{}
+		srv.server = &http.Server{
+			Handler:           srv,
+			Addr:              addr,
+			ReadHeaderTimeout: 3 * time.Second,
 		}
-	}()
+		servers[addr] = srv
+		go func() {
+			if serverErr := srv.server.ListenAndServe(); serverErr != http.ErrServerClosed {
+				log.Printf("[SERVICE: kotel] The Prometheus exporter failed to listen and serve: %v", serverErr)
+			}
+		}()
+	}
+	rh := &registeredHandler{h}
+	srv.mu.Lock()
+	srv.handlers = append(srv.handlers, rh)
+	srv.mu.Unlock()
+	serversMu.Unlock()
 
 	go func() {
 		<-ctx.Done()
+		serversMu.Lock()
+		if srv.remove(rh) > 0 {
+			serversMu.Unlock()
+			return
+		}
+		delete(servers, addr)
+		serversMu.Unlock()
+
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		server.Shutdown(ctx)
+		srv.server.Shutdown(ctx)
 		cancel()
 	}()
-
-	return &PrometheusCollector{
-		registry:          prometheusRegistry,
-		exporter:          exporter,
-		disabledByDefault: cfg.DisableMetrics,
-	}, nil
 }
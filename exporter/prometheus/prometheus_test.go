@@ -0,0 +1,63 @@
+package prometheus
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+
+	"github.com/krakend/krakend-otel/config"
+)
+
+func TestPrometheusCollector_multipleReaders(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Errorf("cannot get a free port: %s", err.Error())
+		return
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	c, err := Exporter(ctx, config.PrometheusExporter{Name: "prom", Host: "127.0.0.1", Port: port})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+
+	first := c.MetricReader(0)
+	second := c.MetricReader(0)
+	if first == second {
+		t.Errorf("expected a different reader for each call")
+		return
+	}
+
+	for _, r := range []sdkmetric.Reader{first, second} {
+		mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(r))
+		counter, _ := mp.Meter("test").Int64Counter("requests")
+		counter.Add(ctx, 1, metric.WithAttributes(attribute.String("route", "/common")))
+		if r == second {
+			counter.Add(ctx, 1, metric.WithAttributes(attribute.String("route", "/selected")))
+		}
+	}
+
+	mfs, err := c.Gather()
+	if err != nil {
+		t.Errorf("unexpected error gathering: %s", err.Error())
+		return
+	}
+	counts := map[string]int{}
+	for _, mf := range mfs {
+		counts[mf.GetName()] = len(mf.Metric)
+	}
+	if counts["requests_total"] != 2 {
+		t.Errorf("expected the series of both readers once, got %d", counts["requests_total"])
+	}
+	if counts["target_info"] != 1 {
+		t.Errorf("the target info must be reported once, got %d", counts["target_info"])
+	}
+}
@@ -0,0 +1,173 @@
+package statsd
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// replacer removes the characters that have a special meaning in the
+// StatsD line protocol (and in the supported tag formats) from the
+// metric names and tags.
+var replacer = strings.NewReplacer(
+	":", "_", "|", "_", "@", "_", "#", "_",
+	",", "_", "=", "_", " ", "_", "\n", "_")
+
+// formatter converts the collected metrics to StatsD lines.
+type formatter struct {
+	prefix        string
+	tagFormat     string
+	histogramType string
+}
+
+func (f formatter) appendMetric(lines []string, m metricdata.Metrics) []string {
+	switch data := m.Data.(type) {
+	case metricdata.Sum[int64]:
+		return appendSum(f, lines, m.Name, data)
+	case metricdata.Sum[float64]:
+		return appendSum(f, lines, m.Name, data)
+	case metricdata.Gauge[int64]:
+		for _, dp := range data.DataPoints {
+			lines = appendGauge(f, lines, m.Name, dp.Attributes, float64(dp.Value))
+		}
+	case metricdata.Gauge[float64]:
+		for _, dp := range data.DataPoints {
+			lines = appendGauge(f, lines, m.Name, dp.Attributes, dp.Value)
+		}
+	case metricdata.Histogram[int64]:
+		return appendHistogram(f, lines, m.Name, m.Unit, data)
+	case metricdata.Histogram[float64]:
+		return appendHistogram(f, lines, m.Name, m.Unit, data)
+	}
+	// other aggregations (like exponential histograms) are not
+	// selected by the exporter, and are ignored
+	return lines
+}
+
+// appendSum reports monotonic sums as counters (with the delta values), and
+// the non monotonic ones as gauges.
+func appendSum[N int64 | float64](f formatter, lines []string, name string, data metricdata.Sum[N]) []string {
+	for _, dp := range data.DataPoints {
+		if data.IsMonotonic {
+			lines = append(lines, f.line(name, dp.Attributes, formatValue(float64(dp.Value)), "c", 0))
+			continue
+		}
+		lines = appendGauge(f, lines, name, dp.Attributes, float64(dp.Value))
+	}
+	return lines
+}
+
+// appendGauge adds a gauge line: as a negative value is interpreted as a
+// decrement by StatsD agents, the gauge is reset to zero before it.
+func appendGauge(f formatter, lines []string, name string, attrs attribute.Set, v float64) []string {
+	if v < 0 {
+		lines = append(lines, f.line(name, attrs, "0", "g", 0))
+	}
+	return append(lines, f.line(name, attrs, formatValue(v), "g", 0))
+}
+
+// appendHistogram adds, for each non empty bucket, a line with a value that
+// represents the bucket (its middle point, within the recorded min and max),
+// and a sample rate that tells the agent how many times it was recorded.
+func appendHistogram[N int64 | float64](f formatter, lines []string, name, unit string,
+	data metricdata.Histogram[N],
+) []string {
+	metricType := "ms"
+	switch f.histogramType {
+	case "histogram":
+		metricType = "h"
+	case "distribution":
+		metricType = "d"
+	}
+	scale := 1.0
+	if metricType == "ms" && unit == "s" {
+		scale = 1000
+	}
+
+	for _, dp := range data.DataPoints {
+		minV, hasMin := dp.Min.Value()
+		maxV, hasMax := dp.Max.Value()
+		for i, count := range dp.BucketCounts {
+			if count == 0 {
+				continue
+			}
+			var lo, hi float64
+			hasLo, hasHi := i > 0, i < len(dp.Bounds)
+			if hasLo {
+				lo = dp.Bounds[i-1]
+			}
+			if hasHi {
+				hi = dp.Bounds[i]
+			}
+			if hasMin && (!hasLo || float64(minV) > lo) {
+				lo, hasLo = float64(minV), true
+			}
+			if hasMax && (!hasHi || float64(maxV) < hi) {
+				hi, hasHi = float64(maxV), true
+			}
+
+			var v float64
+			switch {
+			case hasLo && hasHi:
+				v = (lo + hi) / 2
+			case hasHi:
+				v = hi
+			case hasLo:
+				v = lo
+			default:
+				v = float64(dp.Sum) / float64(dp.Count)
+			}
+			// the value is an approximation: we remove the floating
+			// point noise of the computations from it
+			v = math.Round(v*scale*1e6) / 1e6
+			lines = append(lines, f.line(name, dp.Attributes, formatValue(v), metricType, count))
+		}
+	}
+	return lines
+}
+
+// line builds a StatsD line, adding the sample rate when the
+// value represents more than one measurement.
+func (f formatter) line(name string, attrs attribute.Set, value, metricType string, count uint64) string {
+	var b strings.Builder
+	b.WriteString(replacer.Replace(f.prefix + name))
+
+	if f.tagFormat == "influxdb" {
+		for _, kv := range attrs.ToSlice() {
+			b.WriteByte(',')
+			b.WriteString(replacer.Replace(string(kv.Key)))
+			b.WriteByte('=')
+			b.WriteString(replacer.Replace(kv.Value.Emit()))
+		}
+	}
+
+	b.WriteByte(':')
+	b.WriteString(value)
+	b.WriteByte('|')
+	b.WriteString(metricType)
+
+	if count > 1 {
+		b.WriteString("|@")
+		b.WriteString(strconv.FormatFloat(1/float64(count), 'g', 6, 64))
+	}
+
+	if (f.tagFormat == "" || f.tagFormat == "dogstatsd") && attrs.Len() > 0 {
+		b.WriteString("|#")
+		for i, kv := range attrs.ToSlice() {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(replacer.Replace(string(kv.Key)))
+			b.WriteByte(':')
+			b.WriteString(replacer.Replace(kv.Value.Emit()))
+		}
+	}
+	return b.String()
+}
+
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,177 @@
+// Package statsd implements a StatsD / DogStatsD metrics exporter.
+package statsd
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/krakend/krakend-otel/config"
+)
+
+const (
+	defaultAddress       = "127.0.0.1:8125"
+	defaultMaxPacketSize = 1432
+)
+
+// StatsDCollector implements the metrics exporter.
+type StatsDCollector struct {
+	exporter                    *statsdExporter
+	metricsDisabledByDefault    bool
+	customMetricReportingPeriod time.Duration
+}
+
+// MetricReader implements the interface to export metrics.
+func (c *StatsDCollector) MetricReader(reportingPeriod time.Duration) sdkmetric.Reader {
+	if c.customMetricReportingPeriod >= time.Second {
+		reportingPeriod = c.customMetricReportingPeriod
+	}
+	return sdkmetric.NewPeriodicReader(c.exporter,
+		sdkmetric.WithInterval(reportingPeriod))
+}
+
+func (c *StatsDCollector) MetricDefaultReporting() bool {
+	return !c.metricsDisabledByDefault
+}
+
+// Exporter creates a StatsD exporter instance.
+func Exporter(_ context.Context, cfg config.StatsDExporter) (*StatsDCollector, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	network, address := "udp", strings.TrimPrefix(cfg.Address, "udp://")
+	if strings.HasPrefix(cfg.Address, "unix://") {
+		network, address = "unixgram", strings.TrimPrefix(cfg.Address, "unix://")
+	}
+	if address == "" {
+		address = defaultAddress
+	}
+	maxPacketSize := cfg.MaxPacketSize
+	if maxPacketSize == 0 {
+		maxPacketSize = defaultMaxPacketSize
+	}
+
+	return &StatsDCollector{
+		exporter: &statsdExporter{
+			network:       network,
+			address:       address,
+			maxPacketSize: maxPacketSize,
+			f: formatter{
+				prefix:        cfg.Prefix,
+				tagFormat:     cfg.TagFormat,
+				histogramType: cfg.HistogramType,
+			},
+		},
+		metricsDisabledByDefault:    cfg.DisableMetrics,
+		customMetricReportingPeriod: time.Duration(cfg.CustomMetricReportingPeriod) * time.Second,
+	}, nil
+}
+
+// statsdExporter implements the [sdkmetric.Exporter] interface, sending
+// the collected metrics as StatsD lines grouped in datagrams.
+//
+// Counters and histograms use delta temporality, as StatsD agents
+// aggregate the received values, while up down counters are reported
+// as gauges with their cumulative value.
+type statsdExporter struct {
+	network       string
+	address       string
+	maxPacketSize int
+	f             formatter
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func (*statsdExporter) Temporality(k sdkmetric.InstrumentKind) metricdata.Temporality {
+	switch k {
+	case sdkmetric.InstrumentKindUpDownCounter, sdkmetric.InstrumentKindObservableUpDownCounter:
+		return metricdata.CumulativeTemporality
+	}
+	return metricdata.DeltaTemporality
+}
+
+func (*statsdExporter) Aggregation(k sdkmetric.InstrumentKind) sdkmetric.Aggregation {
+	return sdkmetric.DefaultAggregationSelector(k)
+}
+
+func (e *statsdExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	var lines []string
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			lines = e.f.appendMetric(lines, m)
+		}
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.conn == nil {
+		// the connection is lazily created, so a unix socket
+		// does not need to exist when the exporter is created.
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, e.network, e.address)
+		if err != nil {
+			return err
+		}
+		e.conn = conn
+	}
+
+	var errs []error
+	for _, p := range packets(lines, e.maxPacketSize) {
+		if _, err := e.conn.Write(p); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		// force a new connection for the next export
+		e.conn.Close()
+		e.conn = nil
+	}
+	return errors.Join(errs...)
+}
+
+func (*statsdExporter) ForceFlush(context.Context) error {
+	return nil
+}
+
+func (e *statsdExporter) Shutdown(context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.conn == nil {
+		return nil
+	}
+	err := e.conn.Close()
+	e.conn = nil
+	return err
+}
+
+// packets groups the lines, separated by new lines, in payloads of up to
+// maxSize bytes (a single line bigger than that is sent alone).
+func packets(lines []string, maxSize int) [][]byte {
+	var res [][]byte
+	var buf []byte
+	for _, l := range lines {
+		if len(buf) > 0 && len(buf)+1+len(l) > maxSize {
+			res = append(res, buf)
+			buf = nil
+		}
+		if len(buf) > 0 {
+			buf = append(buf, '\n')
+		}
+		buf = append(buf, l...)
+	}
+	if len(buf) > 0 {
+		res = append(res, buf)
+	}
+	return res
+}
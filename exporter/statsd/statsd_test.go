@@ -0,0 +1,200 @@
+package statsd
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/krakend/krakend-otel/config"
+)
+
+func TestExporter_udp(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Errorf("cannot listen: %s", err.Error())
+		return
+	}
+	defer pc.Close()
+
+	exp, err := Exporter(context.Background(), config.StatsDExporter{
+		Name:    "statsd",
+		Address: "udp://" + pc.LocalAddr().String(),
+		Prefix:  "krakend.",
+	})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(exp.MetricReader(time.Minute)))
+	meter := mp.Meter("test")
+	attrs := metric.WithAttributes(attribute.String("method", "GET"))
+
+	counter, _ := meter.Int64Counter("requests")
+	inflight, _ := meter.Int64UpDownCounter("inflight")
+	latency, _ := meter.Float64Histogram("latency", metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(0.1, 1))
+
+	ctx := context.Background()
+	counter.Add(ctx, 2, attrs)
+	inflight.Add(ctx, 3)
+	latency.Record(ctx, 0.05, attrs)
+	latency.Record(ctx, 0.07, attrs)
+
+	if err := mp.ForceFlush(ctx); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	got := readLines(t, pc)
+	expected := []string{
+		"krakend.inflight:3|g",
+		"krakend.latency:60|ms|@0.5|#method:GET",
+		"krakend.requests:2|c|#method:GET",
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected lines:\n%v\nexpected:\n%v", got, expected)
+	}
+
+	// counters use delta temporality, so only the new increment
+	// is reported in the next export
+	counter.Add(ctx, 1, attrs)
+	if err := mp.ForceFlush(ctx); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	got = readLines(t, pc)
+	expected = []string{
+		"krakend.inflight:3|g",
+		"krakend.requests:1|c|#method:GET",
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected lines:\n%v\nexpected:\n%v", got, expected)
+	}
+	mp.Shutdown(ctx)
+}
+
+func TestFormatter_tagFormats(t *testing.T) {
+	attrs := attribute.NewSet(attribute.String("method", "GET"), attribute.Int("status", 200))
+	for _, tc := range []struct {
+		tagFormat string
+		expected  string
+	}{
+		{"", "kd.hits:1|c|#method:GET,status:200"},
+		{"dogstatsd", "kd.hits:1|c|#method:GET,status:200"},
+		{"influxdb", "kd.hits,method=GET,status=200:1|c"},
+		{"none", "kd.hits:1|c"},
+	} {
+		f := formatter{prefix: "kd.", tagFormat: tc.tagFormat}
+		if got := f.line("hits", attrs, "1", "c", 0); got != tc.expected {
+			t.Errorf("%q: expected %s, got %s", tc.tagFormat, tc.expected, got)
+		}
+	}
+}
+
+func TestFormatter_sanitize(t *testing.T) {
+	f := formatter{}
+	attrs := attribute.NewSet(attribute.String("url", "a:b|c,d"))
+	expected := "bad_name_:1|c|#url:a_b_c_d"
+	if got := f.line("bad name:", attrs, "1", "c", 0); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestFormatter_histogram(t *testing.T) {
+	data := metricdata.Histogram[float64]{
+		Temporality: metricdata.DeltaTemporality,
+		DataPoints: []metricdata.HistogramDataPoint[float64]{
+			{
+				Count:        4,
+				Bounds:       []float64{10, 100},
+				BucketCounts: []uint64{1, 0, 3},
+				Min:          metricdata.NewExtrema(4.0),
+				Max:          metricdata.NewExtrema(300.0),
+				Sum:          904,
+			},
+		},
+	}
+	m := metricdata.Metrics{Name: "size", Unit: "By", Data: data}
+	for _, tc := range []struct {
+		histogramType string
+		expected      []string
+	}{
+		{"", []string{"size:7|ms", "size:200|ms|@0.333333"}},
+		{"histogram", []string{"size:7|h", "size:200|h|@0.333333"}},
+		{"distribution", []string{"size:7|d", "size:200|d|@0.333333"}},
+	} {
+		f := formatter{histogramType: tc.histogramType}
+		got := f.appendMetric(nil, m)
+		if strings.Join(got, "\n") != strings.Join(tc.expected, "\n") {
+			t.Errorf("%q: expected %v, got %v", tc.histogramType, tc.expected, got)
+		}
+	}
+}
+
+func TestFormatter_negativeGauge(t *testing.T) {
+	m := metricdata.Metrics{
+		Name: "temperature",
+		Data: metricdata.Gauge[int64]{
+			DataPoints: []metricdata.DataPoint[int64]{{Value: -5}},
+		},
+	}
+	got := formatter{}.appendMetric(nil, m)
+	expected := []string{"temperature:0|g", "temperature:-5|g"}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestPackets(t *testing.T) {
+	lines := []string{"aaaa:1|c", "bbbb:1|c", "cccc:1|c", "a_very_long_metric_name:1|c"}
+	got := packets(lines, 17)
+	expected := []string{"aaaa:1|c\nbbbb:1|c", "cccc:1|c", "a_very_long_metric_name:1|c"}
+	if len(got) != len(expected) {
+		t.Errorf("expected %d packets, got %d", len(expected), len(got))
+		return
+	}
+	for i, p := range got {
+		if string(p) != expected[i] {
+			t.Errorf("packet %d: expected %q, got %q", i, expected[i], p)
+		}
+	}
+}
+
+func TestExporter_badConfig(t *testing.T) {
+	for _, cfg := range []config.StatsDExporter{
+		{Name: "bad_address", Address: "localhost"},
+		{Name: "empty_socket", Address: "unix://"},
+		{Name: "bad_tags", TagFormat: "graphite"},
+		{Name: "bad_histogram", HistogramType: "summary"},
+	} {
+		if _, err := Exporter(context.Background(), cfg); err == nil {
+			t.Errorf("%s: expected error", cfg.Name)
+		}
+	}
+}
+
+// readLines reads the datagrams received until there are no more
+// pending, and returns all their lines sorted.
+func readLines(t *testing.T, pc net.PacketConn) []string {
+	t.Helper()
+	var lines []string
+	buf := make([]byte, 65536)
+	for {
+		pc.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			break
+		}
+		lines = append(lines, strings.Split(string(buf[:n]), "\n")...)
+	}
+	sort.Strings(lines)
+	return lines
+}
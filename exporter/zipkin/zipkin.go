@@ -0,0 +1,69 @@
+// Package zipkin implements a Zipkin traces exporter.
+package zipkin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/exporters/zipkin"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+
+	"github.com/krakend/krakend-otel/config"
+)
+
+const defaultTimeout = 10 * time.Second
+
+// ZipkinCollector implements the traces exporter.
+type ZipkinCollector struct {
+	exporter                sdktrace.SpanExporter
+	tracesDisabledByDefault bool
+	batchOpts               *config.BatchOpts
+}
+
+// SpanExporter implements the interface to export traces.
+func (c *ZipkinCollector) SpanExporter() sdktrace.SpanExporter {
+	return c.exporter
+}
+
+// BatchOpts returns the span processor settings for the exporter.
+func (c *ZipkinCollector) BatchOpts() *config.BatchOpts {
+	return c.batchOpts
+}
+
+func (c *ZipkinCollector) TraceDefaultReporting() bool {
+	return !c.tracesDisabledByDefault
+}
+
+// Exporter creates a Zipkin exporter instance.
+func Exporter(_ context.Context, cfg config.ZipkinExporter) (*ZipkinCollector, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	timeout, err := config.ParseDuration(cfg.Timeout, defaultTimeout)
+	if err != nil {
+		return nil, err
+	}
+	headers, err := config.ResolveValues(cfg.Headers)
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve headers: %s", err.Error())
+	}
+
+	opts := []zipkin.Option{
+		zipkin.WithClient(&http.Client{Timeout: timeout}),
+	}
+	if len(headers) > 0 {
+		opts = append(opts, zipkin.WithHeaders(headers))
+	}
+	exporter, err := zipkin.New(cfg.URL, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ZipkinCollector{
+		exporter:                exporter,
+		tracesDisabledByDefault: cfg.DisableTraces,
+		batchOpts:               cfg.Batch,
+	}, nil
+}
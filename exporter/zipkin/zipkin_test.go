@@ -0,0 +1,110 @@
+package zipkin
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+
+	"github.com/krakend/krakend-otel/config"
+)
+
+type zipkinSpan struct {
+	TraceID string `json:"traceId"`
+	Name    string `json:"name"`
+}
+
+// zipkinCollector is a Zipkin stand-in that records the
+// received spans and headers.
+type zipkinCollector struct {
+	mu      sync.Mutex
+	spans   []zipkinSpan
+	headers http.Header
+}
+
+func (c *zipkinCollector) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != "/api/v2/spans" || req.Method != http.MethodPost {
+		rw.WriteHeader(http.StatusNotFound)
+		return
+	}
+	b, _ := io.ReadAll(req.Body)
+	var spans []zipkinSpan
+	if err := json.Unmarshal(b, &spans); err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	c.spans = append(c.spans, spans...)
+	c.headers = req.Header.Clone()
+	c.mu.Unlock()
+	rw.WriteHeader(http.StatusAccepted)
+}
+
+func TestExporter(t *testing.T) {
+	collector := &zipkinCollector{}
+	srv := httptest.NewServer(collector)
+	defer srv.Close()
+
+	t.Setenv("KOTEL_TEST_ZIPKIN_TOKEN", "s3cr3t")
+	exp, err := Exporter(context.Background(), config.ZipkinExporter{
+		Name: "zipkin",
+		URL:  srv.URL + "/api/v2/spans",
+		Headers: map[string]string{
+			"Authorization": "env:KOTEL_TEST_ZIPKIN_TOKEN",
+		},
+		Timeout:       "2s",
+		DisableTraces: true,
+	})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	if exp.TraceDefaultReporting() {
+		t.Errorf("expected traces to be disabled by default")
+	}
+
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp.SpanExporter()))
+	_, span := tp.Tracer("test").Start(context.Background(), "test-span")
+	traceID := span.SpanContext().TraceID().String()
+	span.End()
+	if err := tp.Shutdown(context.Background()); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	if len(collector.spans) != 1 {
+		t.Errorf("expected 1 span, got %d", len(collector.spans))
+		return
+	}
+	if collector.spans[0].Name != "test-span" {
+		t.Errorf("expected span name test-span, got %s", collector.spans[0].Name)
+	}
+	if collector.spans[0].TraceID != traceID {
+		t.Errorf("expected trace id %s, got %s", traceID, collector.spans[0].TraceID)
+	}
+	if h := collector.headers.Get("Authorization"); h != "s3cr3t" {
+		t.Errorf("expected the resolved Authorization header, got %q", h)
+	}
+}
+
+func TestExporter_badConfig(t *testing.T) {
+	for _, cfg := range []config.ZipkinExporter{
+		{Name: "no_url"},
+		{Name: "bad_scheme", URL: "ftp://localhost:9411/api/v2/spans"},
+		{Name: "bad_timeout", URL: "http://localhost:9411/api/v2/spans", Timeout: "soon"},
+		{Name: "missing_env", URL: "http://localhost:9411/api/v2/spans", Headers: map[string]string{
+			"Authorization": "env:KOTEL_TEST_ZIPKIN_UNDEFINED",
+		}},
+	} {
+		if _, err := Exporter(context.Background(), cfg); err == nil {
+			t.Errorf("%s: expected error", cfg.Name)
+		}
+	}
+}
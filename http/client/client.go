@@ -51,9 +51,16 @@ func InstrumentedHTTPClient(c *http.Client, t *TransportOptions, clientName stri
 		transport = http.DefaultTransport
 	}
 
-	rtt := NewRoundTripper(transport, t.MetricsOpts, t.TracesOpts, clientName, t.OTELInstance)
-	if rtt == nil {
-		return c
+	var rtt http.RoundTripper
+	if t.OTELGetter != nil {
+		rtt = NewRoundTripperWithGetter(transport, t.MetricsOpts, t.TracesOpts, clientName, t.OTELGetter)
+	} else {
+		rtt = NewRoundTripper(transport, t.MetricsOpts, t.TracesOpts, clientName, t.OTELInstance)
+	}
+	if _, ok := rtt.(*Transport); !ok {
+		// the header that forced the sampling must never reach
+		// the backend, even without instrumentation
+		rtt = &forceSamplingTransport{base: transport}
 	}
 	wc := &http.Client{
 		Transport:     rtt,
@@ -18,6 +18,8 @@ import (
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	sdktracetest "go.opentelemetry.io/otel/sdk/trace/tracetest"
 	"go.opentelemetry.io/otel/trace"
+
+	"github.com/krakend/krakend-otel/state"
 )
 
 type fakeService struct{}
@@ -223,3 +225,127 @@ func TestInstrumentedHTTPClient(t *testing.T) {
 		return
 	}
 }
+
+func TestInstrumentedHTTPClient_propagation(t *testing.T) {
+	var received http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+		w.Write([]byte("foo bar"))
+	}))
+	defer server.Close()
+
+	for _, tc := range []struct {
+		name     string
+		disable  bool
+		expected bool
+	}{
+		{"injected", false, true},
+		{"disabled", true, false},
+	} {
+		otelInstance := newTestOTEL()
+		c := InstrumentedHTTPClient(&http.Client{}, &TransportOptions{
+			OTELInstance: otelInstance,
+			TracesOpts: TransportTracesOptions{
+				RoundTrip:          true,
+				DisablePropagation: tc.disable,
+				Propagator:         propagation.TraceContext{},
+			},
+		}, "test-http-client")
+
+		req, _ := http.NewRequest("GET", server.URL, http.NoBody)
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Errorf("%s: unexpected client error: %s", tc.name, err.Error())
+			return
+		}
+		io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if hasHeader := received.Get("Traceparent") != ""; hasHeader != tc.expected {
+			t.Errorf("%s: expected traceparent header %v", tc.name, tc.expected)
+		}
+		if n := len(otelInstance.spanRecorder.Ended()); n != 1 {
+			t.Errorf("%s: expected the client span to be recorded, got %d spans", tc.name, n)
+		}
+	}
+}
+
+func TestInstrumentedHTTPClient_forceSamplingSecret(t *testing.T) {
+	var received http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+		w.Write([]byte("foo bar"))
+	}))
+	defer server.Close()
+
+	for _, tc := range []struct {
+		name     string
+		opts     TransportOptions
+		expected string
+		spans    int
+	}{
+		{
+			name: "propagated",
+			opts: TransportOptions{TracesOpts: TransportTracesOptions{
+				RoundTrip:     true,
+				ReportHeaders: true,
+				Propagator:    propagation.TraceContext{},
+			}},
+			expected: state.ForcedSamplingValue,
+			spans:    1,
+		},
+		{
+			name: "propagation_disabled",
+			opts: TransportOptions{TracesOpts: TransportTracesOptions{
+				RoundTrip:          true,
+				ReportHeaders:      true,
+				DisablePropagation: true,
+			}},
+			spans: 1,
+		},
+		{
+			name: "traces_disabled",
+			opts: TransportOptions{MetricsOpts: TransportMetricsOptions{RoundTrip: true}},
+		},
+		{
+			name: "not_instrumented",
+		},
+	} {
+		otelInstance := newTestOTEL()
+		opts := tc.opts
+		opts.OTELInstance = otelInstance
+		c := InstrumentedHTTPClient(&http.Client{}, &opts, "test-http-client")
+
+		ctx := state.ContextWithForceSampling(context.Background(), "X-Debug-Trace")
+		req, _ := http.NewRequestWithContext(ctx, "GET", server.URL, http.NoBody)
+		// the incoming header forwarded to the backend
+		req.Header.Set("X-Debug-Trace", "s3cr3t")
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Errorf("%s: unexpected client error: %s", tc.name, err.Error())
+			return
+		}
+		io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if v := received.Get("X-Debug-Trace"); v != tc.expected {
+			t.Errorf("%s: expected force sampling header %q, got %q", tc.name, tc.expected, v)
+		}
+		if req.Header.Get("X-Debug-Trace") != "s3cr3t" {
+			t.Errorf("%s: the original request has been modified", tc.name)
+		}
+		spans := otelInstance.spanRecorder.Ended()
+		if len(spans) != tc.spans {
+			t.Errorf("%s: expected %d client spans, got %d", tc.name, tc.spans, len(spans))
+			continue
+		}
+		for _, s := range spans {
+			for _, kv := range s.Attributes() {
+				if kv.Key == "http.request.header.x-debug-trace" {
+					t.Errorf("%s: the force sampling header has been reported: %v", tc.name,
+						kv.Value.AsStringSlice())
+				}
+			}
+		}
+	}
+}
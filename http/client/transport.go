@@ -20,14 +20,16 @@ import (
 // the metrics and traces.
 // See [TrasnportMetricsOptions] and [TransportTracesOptions] for
 // more details.
-// The OTELInstance member defines a function to obtain a State to
-// use with this transport. The state getter is used at configuration
-// time, not at runtime.
+// The OTELInstance member defines the State to use with this transport,
+// and it is used at configuration time. When the OTELGetter function is
+// set, it takes precedence and the state is obtained at runtime, so the
+// transport picks up a new state after a reload.
 type TransportOptions struct {
 	MetricsOpts TransportMetricsOptions
 	TracesOpts  TransportTracesOptions
 
 	OTELInstance state.OTEL
+	OTELGetter   state.GetterFn
 }
 
 // readerWrapper defines a function to wrap a reader
@@ -47,10 +49,6 @@ type Transport struct {
 	// the returned round tripper will be cancelable.
 	base http.RoundTripper
 
-	// Propagation defines how traces are propagated. If unspecified, a default
-	// (currently B3 format can be configured outside) will be used.
-	propagator propagation.TextMapPropagator
-
 	// StartOptions are applied to the span started by this Transport around each
 	// request.
 	//
@@ -60,7 +58,16 @@ type Transport struct {
 
 	tracesOpts  TransportTracesOptions
 	metricsOpts TransportMetricsOptions
-	otelState   state.OTEL
+
+	instruments *state.Instruments[*transportInstruments]
+}
+
+// transportInstruments are the parts of the transport that depend on the
+// [state.OTEL] instance, so they can be replaced after a reload.
+type transportInstruments struct {
+	// Propagation defines how traces are propagated. If unspecified, a default
+	// (currently B3 format can be configured outside) will be used.
+	propagator propagation.TextMapPropagator
 
 	metrics *transportMetrics
 	traces  *transportTraces
@@ -107,7 +114,19 @@ func readWrapperBuilder(metricsOpts *TransportMetricsOptions, tracesOpts *Transp
 func NewRoundTripper(base http.RoundTripper, metricsOpts TransportMetricsOptions,
 	tracesOpts TransportTracesOptions, clientName string, otelState state.OTEL,
 ) http.RoundTripper {
-	rt := newTransport(base, metricsOpts, tracesOpts, clientName, otelState)
+	if otelState == nil {
+		return base
+	}
+	return NewRoundTripperWithGetter(base, metricsOpts, tracesOpts, clientName,
+		func() state.OTEL { return otelState })
+}
+
+// NewRoundTripperWithGetter creates an instrumented round tripper that
+// obtains the state to use with the getter for each request.
+func NewRoundTripperWithGetter(base http.RoundTripper, metricsOpts TransportMetricsOptions,
+	tracesOpts TransportTracesOptions, clientName string, getter state.GetterFn,
+) http.RoundTripper {
+	rt := newTransport(base, metricsOpts, tracesOpts, clientName, getter)
 	if rt == nil {
 		return base
 	}
@@ -115,56 +134,115 @@ func NewRoundTripper(base http.RoundTripper, metricsOpts TransportMetricsOptions
 }
 
 func newTransport(base http.RoundTripper, metricsOpts TransportMetricsOptions,
-	tracesOpts TransportTracesOptions, clientName string, otelState state.OTEL,
+	tracesOpts TransportTracesOptions, clientName string, getter state.GetterFn,
 ) *Transport {
 	if !tracesOpts.Enabled() && !metricsOpts.Enabled() {
 		return nil
 	}
-	if otelState == nil {
+	if getter == nil {
 		return nil
 	}
 
-	var meter metric.Meter
-	if metricsOpts.Enabled() {
-		meter = otelState.Meter()
-	}
-	var tracer trace.Tracer
-	if tracesOpts.Enabled() {
-		tracer = otelState.Tracer()
+	build := func(otelState state.OTEL) *transportInstruments {
+		var meter metric.Meter
+		var tracer trace.Tracer
+		var propagator propagation.TextMapPropagator
+		if otelState != nil {
+			propagator = otelState.Propagator()
+			if metricsOpts.Enabled() {
+				meter = otelState.Meter()
+			}
+			if tracesOpts.Enabled() {
+				tracer = otelState.Tracer()
+			}
+		}
+		if tracesOpts.Propagator != nil {
+			propagator = tracesOpts.Propagator
+		}
+		if propagator == nil {
+			propagator = otel.GetTextMapPropagator()
+		}
+		if tracesOpts.DisablePropagation {
+			propagator = nil
+		}
+		return &transportInstruments{
+			propagator:    propagator,
+			metrics:       newTransportMetrics(&metricsOpts, meter, clientName),
+			traces:        newTransportTraces(&tracesOpts, tracer, clientName),
+			readerWrapper: readWrapperBuilder(&metricsOpts, &tracesOpts, meter, tracer),
+		}
 	}
 
 	return &Transport{
-		base:          base,
-		propagator:    otel.GetTextMapPropagator(),
-		otelState:     otelState,
-		tracesOpts:    tracesOpts,
-		metricsOpts:   metricsOpts,
-		metrics:       newTransportMetrics(&metricsOpts, meter, clientName),
-		traces:        newTransportTraces(&tracesOpts, tracer, clientName),
-		readerWrapper: readWrapperBuilder(&metricsOpts, &tracesOpts, meter, tracer),
+		base:        base,
+		tracesOpts:  tracesOpts,
+		metricsOpts: metricsOpts,
+		instruments: state.NewInstruments(getter, build),
 	}
 }
 
 // RoundTrip implements http.RoundTripper, delegating to Base and recording
 // metrics and traces for the request.
 func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ti := t.instruments.Get()
 	rtt := roundTripTracking{
 		req: req,
 	}
 	if t.tracesOpts.DetailedConnection || t.metricsOpts.DetailedConnection {
 		rtt.withClientTrace()
 	}
-	t.traces.start(&rtt, t.propagator)
+	rtt.req = sanitizeForcedSampling(rtt.req, ti.traces != nil && ti.propagator != nil)
+	ti.traces.start(&rtt, ti.propagator)
 
 	requestSentAt := time.Now()
 	rtt.resp, rtt.err = t.base.RoundTrip(rtt.req)
 	rtt.latencyInSecs = float64(time.Since(requestSentAt)) / float64(time.Second)
 
-	t.metrics.report(&rtt, t.metricsOpts.FixedAttributes)
+	ti.metrics.report(&rtt, t.metricsOpts.FixedAttributes)
 
 	if rtt.resp != nil && rtt.resp.Body != nil {
-		rtt.resp.Body = t.readerWrapper(rtt.resp.Body, rtt.req.Context())
+		rtt.resp.Body = ti.readerWrapper(rtt.resp.Body, rtt.req.Context())
 	}
-	t.traces.end(&rtt)
+	ti.traces.end(&rtt)
 	return rtt.resp, rtt.err
 }
+
+// sanitizeForcedSampling replaces the header that forced the sampling
+// of the request (see [state.ContextWithForceSampling]), that could have
+// been forwarded with a secret, with a fixed marker when the trace is
+// propagated, or removes it otherwise. It returns a copy of the request
+// when the header has to be changed.
+func sanitizeForcedSampling(req *http.Request, propagate bool) *http.Request {
+	if req == nil {
+		return req
+	}
+	h, forced := state.ForceSampling(req.Context())
+	if !forced || h == "" {
+		return req
+	}
+	if !propagate && len(req.Header.Values(h)) == 0 {
+		return req
+	}
+	r := new(http.Request)
+	*r = *req
+	r.Header = req.Header.Clone()
+	if r.Header == nil {
+		r.Header = make(http.Header, 1)
+	}
+	if propagate {
+		r.Header.Set(h, state.ForcedSamplingValue)
+	} else {
+		r.Header.Del(h)
+	}
+	return r
+}
+
+// forceSamplingTransport only removes the header that forced the
+// sampling from the requests that are not instrumented.
+type forceSamplingTransport struct {
+	base http.RoundTripper
+}
+
+func (t *forceSamplingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.base.RoundTrip(sanitizeForcedSampling(req, false))
+}
@@ -13,6 +13,7 @@ import (
 	v127 "go.opentelemetry.io/otel/semconv/v1.27.0"
 
 	kotelconfig "github.com/krakend/krakend-otel/config"
+	"github.com/krakend/krakend-otel/state"
 )
 
 // TransportMetricsOptions contains the options to enable / disable
@@ -24,6 +25,8 @@ type TransportMetricsOptions struct {
 	DetailedConnection bool                 // provide detailed metrics about the connection: dns lookup, tls ...
 	FixedAttributes    []attribute.KeyValue // "static" attributes set at config time.
 	SemConv            string               // to use the latest metric names conventions
+
+	BaggageAttributes *state.BaggageAttributes // baggage members promoted to attributes
 }
 
 // Enabled tells if metrics should be reported for the transport.
@@ -60,6 +63,8 @@ type transportMetrics struct {
 
 	// to identify the source of the request (in KrakenD the front facing endpoint)
 	clientName string
+
+	baggage *state.BaggageAttributes
 }
 
 type metricFillerFn func(*TransportMetricsOptions, metric.Meter, *transportMetrics)
@@ -161,6 +166,7 @@ func newTransportMetrics(metricsOpts *TransportMetricsOptions, meter metric.Mete
 	}
 	tm := transportMetrics{
 		clientName: clientName,
+		baggage:    metricsOpts.BaggageAttributes,
 	}
 	filler := noSemConvMetricsFiller
 	if versionFiller, ok := supportedSemConv[metricsOpts.SemConv]; ok {
@@ -250,6 +256,7 @@ func (m *transportMetrics) attributesOption(rtt *roundTripTracking, attrs []attr
 		semconv.ServerPort(serverPort),             // required by sem conv 1.29
 		semconv.HTTPResponseStatusCode(statusCode), // required if received
 	)
+	attrM = append(attrM, m.baggage.MetricAttributes(rtt.req.Context())...)
 	return metric.WithAttributeSet(attribute.NewSet(attrM...))
 }
 
@@ -11,6 +11,7 @@ import (
 	"go.opentelemetry.io/otel/trace"
 
 	otelhttp "github.com/krakend/krakend-otel/http"
+	"github.com/krakend/krakend-otel/state"
 )
 
 // TransportTracesOptions defines what information
@@ -23,6 +24,11 @@ type TransportTracesOptions struct {
 	FixedAttributes    []attribute.KeyValue // "static" attributes set at config time.
 	ReportHeaders      bool
 	SkipHeaders        []string
+
+	// DisablePropagation stops injecting the trace context into
+	// the requests, and Propagator overrides the one from the state.
+	DisablePropagation bool
+	Propagator         propagation.TextMapPropagator
 }
 
 // Enabled returns if the transport should create a trace.
@@ -68,7 +74,8 @@ func (t *transportTraces) start(rtt *roundTripTracking,
 		return
 	}
 
-	ctx, span := t.tracer.Start(rtt.req.Context(), t.spanName, trace.WithSpanKind(trace.SpanKindClient))
+	ctx, span := t.tracer.Start(rtt.req.Context(), t.spanName, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(t.fixedAttrs...))
 	if span == nil || !span.IsRecording() {
 		// we might not be recording because of sampling
 		return
@@ -83,18 +90,27 @@ func (t *transportTraces) start(rtt *roundTripTracking,
 	// pass it a copy of the Request.
 	// However, the Request struct itself was already copied by
 	// the WithContext calls above and so we just need to copy the header.
+	forcedHeader, _ := state.ForceSampling(rtt.req.Context())
+	if forcedHeader != "" {
+		forcedHeader = textproto.CanonicalMIMEHeaderKey(forcedHeader)
+	}
 	header := make(http.Header, len(rtt.req.Header)+1)
 	for k, v := range rtt.req.Header {
 		header[k] = v
+		if k == forcedHeader {
+			// it is never reported, like in the server span
+			continue
+		}
 		if t.reportHeaders && (t.skipHeaders == nil || !t.skipHeaders[k]) {
 			reqAttrs = append(reqAttrs,
 				attribute.StringSlice("http.request.header."+strings.ToLower(k), v))
 		}
 	}
 	rtt.req.Header = header
-	propagator.Inject(rtt.req.Context(), propagation.HeaderCarrier(rtt.req.Header))
+	if propagator != nil {
+		propagator.Inject(rtt.req.Context(), propagation.HeaderCarrier(rtt.req.Header))
+	}
 
-	rtt.span.SetAttributes(t.fixedAttrs...)
 	rtt.span.SetAttributes(reqAttrs...)
 }
 
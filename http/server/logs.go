@@ -0,0 +1,63 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"go.opentelemetry.io/otel/log"
+)
+
+const accessLogEventName = "http.server.access"
+
+type logsHTTP struct {
+	logger log.Logger
+}
+
+// report emits an access log record for the request. The trace and
+// span ids are taken from the tracking context, so the record can be
+// correlated with the server span.
+func (l *logsHTTP) report(t *tracking, r *http.Request) {
+	if l == nil || l.logger == nil {
+		return
+	}
+
+	severity := log.SeverityInfo
+	switch {
+	case t.responseStatus >= 500:
+		severity = log.SeverityError
+	case t.responseStatus >= 400:
+		severity = log.SeverityWarn
+	}
+	if !l.logger.Enabled(t.ctx, log.EnabledParameters{
+		Severity:  severity,
+		EventName: accessLogEventName,
+	}) {
+		return
+	}
+
+	urlPath := ""
+	if r.URL != nil {
+		urlPath = r.URL.Path
+	}
+
+	var rec log.Record
+	rec.SetEventName(accessLogEventName)
+	rec.SetTimestamp(t.startTime)
+	rec.SetSeverity(severity)
+	rec.SetSeverityText(severity.String())
+	rec.SetBody(log.StringValue(r.Method + " " + urlPath + " " + strconv.Itoa(t.responseStatus)))
+	rec.AddAttributes(
+		log.String("http.request.method", validMethod(r)),
+		log.String("url.path", urlPath),
+		log.String("http.route", t.EndpointPattern()),
+		log.Int("http.response.status_code", t.responseStatus),
+		log.Int("http.response.body.size", t.responseSize),
+		log.Float64("http.server.request.duration", t.latencyInSecs))
+	if r.ContentLength > 0 {
+		rec.AddAttributes(log.Int64("http.request.body.size", r.ContentLength))
+	}
+	if len(t.writeErrs) > 0 {
+		rec.AddAttributes(log.String("error.message", t.writeErrs[0].Error()))
+	}
+	l.logger.Emit(t.ctx, rec)
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	sdktracetest "go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/krakend/krakend-otel/config"
+	"github.com/krakend/krakend-otel/state"
+)
+
+type testLogExporter struct {
+	mu      sync.Mutex
+	records []sdklog.Record
+}
+
+func (e *testLogExporter) Export(_ context.Context, records []sdklog.Record) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, r := range records {
+		e.records = append(e.records, r.Clone())
+	}
+	return nil
+}
+
+func (*testLogExporter) Shutdown(context.Context) error   { return nil }
+func (*testLogExporter) ForceFlush(context.Context) error { return nil }
+
+func TestTrackingHandler_accessLogs(t *testing.T) {
+	spanRecorder := sdktracetest.NewSpanRecorder()
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spanRecorder))
+	logExporter := &testLogExporter{}
+	loggerProvider := sdklog.NewLoggerProvider(
+		sdklog.WithProcessor(sdklog.NewSimpleProcessor(logExporter)))
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		SetEndpointPattern(r.Context(), "/users/{id}")
+		rw.WriteHeader(http.StatusNotFound)
+		rw.Write([]byte("not found"))
+	})
+	hi := &handlerInstruments{
+		traces: newTracesHTTP(tracerProvider.Tracer("test"), nil, false, nil, nil),
+		logs:   &logsHTTP{logger: loggerProvider.Logger("test")},
+	}
+	h := &trackingHandler{
+		next: next,
+		instruments: state.NewInstruments(func() state.OTEL { return nil },
+			func(state.OTEL) *handlerInstruments { return hi }),
+		config: state.NewConfig(&config.ConfigData{}),
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/users/42", http.NoBody)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := spanRecorder.Ended()
+	if len(spans) != 1 {
+		t.Errorf("expected 1 span, got %d", len(spans))
+		return
+	}
+	logExporter.mu.Lock()
+	defer logExporter.mu.Unlock()
+	if len(logExporter.records) != 1 {
+		t.Errorf("expected 1 log record, got %d", len(logExporter.records))
+		return
+	}
+	rec := logExporter.records[0]
+
+	if rec.TraceID() != spans[0].SpanContext().TraceID() {
+		t.Errorf("expected trace id %s, got %s", spans[0].SpanContext().TraceID(), rec.TraceID())
+	}
+	if rec.SpanID() != spans[0].SpanContext().SpanID() {
+		t.Errorf("expected span id %s, got %s", spans[0].SpanContext().SpanID(), rec.SpanID())
+	}
+	if rec.Severity() != log.SeverityWarn {
+		t.Errorf("expected warn severity for a 404, got %s", rec.Severity())
+	}
+	if got := rec.Body().AsString(); got != "GET /users/42 404" {
+		t.Errorf("unexpected body: %q", got)
+	}
+
+	attrs := map[string]log.Value{}
+	rec.WalkAttributes(func(kv log.KeyValue) bool {
+		attrs[kv.Key] = kv.Value
+		return true
+	})
+	if v := attrs["http.route"].AsString(); v != "/users/{id}" {
+		t.Errorf("unexpected http.route: %q", v)
+	}
+	if v := attrs["http.response.status_code"].AsInt64(); v != 404 {
+		t.Errorf("unexpected http.response.status_code: %d", v)
+	}
+	if v := attrs["http.response.body.size"].AsInt64(); v != 9 {
+		t.Errorf("unexpected http.response.body.size: %d", v)
+	}
+	if _, ok := attrs["http.server.request.duration"]; !ok {
+		t.Errorf("missing http.server.request.duration attribute")
+	}
+}
@@ -10,11 +10,13 @@ import (
 	v127 "go.opentelemetry.io/otel/semconv/v1.27.0"
 
 	kotelconfig "github.com/krakend/krakend-otel/config"
+	"github.com/krakend/krakend-otel/state"
 )
 
 type metricsHTTP struct {
 	fixedAttrs     []attribute.KeyValue
 	fixedAttrsOpts metric.MeasurementOption
+	baggage        *state.BaggageAttributes
 
 	latency metric.Float64Histogram // the time it takes to serve the request
 	size    metric.Int64Histogram   // the response size
@@ -82,6 +84,7 @@ func (m *metricsHTTP) report(t *tracking, r *http.Request) {
 		semconv.URLScheme(urlScheme),                        // required attribute
 		semconv.HTTPRoute(t.EndpointPattern()),              // required if available
 		semconv.HTTPResponseStatusCode(t.responseStatus))    // required if was sent
+	dynAttrs = append(dynAttrs, m.baggage.MetricAttributes(t.ctx)...)
 	dynAttrsOpts := metric.WithAttributes(dynAttrs...)
 
 	m.latency.Record(t.ctx, t.latencyInSecs, m.fixedAttrsOpts, dynAttrsOpts)
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
 type TrackingResponseWriter struct {
@@ -15,6 +17,30 @@ type TrackingResponseWriter struct {
 	flusher        http.Flusher
 	hijacker       http.Hijacker
 	hijackCallback func(net.Conn, error) (net.Conn, error)
+
+	traceResponse   bool
+	traceIDHeader   string
+	traceHeadersSet bool
+}
+
+// setTraceHeaders adds the headers with the trace identifiers, if
+// enabled, before the response headers are sent.
+func (w *TrackingResponseWriter) setTraceHeaders() {
+	if w.traceHeadersSet || (!w.traceResponse && w.traceIDHeader == "") {
+		return
+	}
+	w.traceHeadersSet = true
+	sc := trace.SpanContextFromContext(w.track.ctx)
+	if !sc.IsValid() {
+		return
+	}
+	h := w.rw.Header()
+	if w.traceResponse {
+		h.Set("traceresponse", "00-"+sc.TraceID().String()+"-"+sc.SpanID().String()+"-"+sc.TraceFlags().String())
+	}
+	if w.traceIDHeader != "" {
+		h.Set(w.traceIDHeader, sc.TraceID().String())
+	}
 }
 
 func (w *TrackingResponseWriter) gatherHeaders() {
@@ -39,6 +65,7 @@ func (w *TrackingResponseWriter) Header() http.Header {
 }
 
 func (w *TrackingResponseWriter) Write(b []byte) (int, error) {
+	w.setTraceHeaders()
 	w.gatherHeaders()
 	nBytes, e := w.rw.Write(b)
 	if e != nil {
@@ -49,6 +76,7 @@ func (w *TrackingResponseWriter) Write(b []byte) (int, error) {
 }
 
 func (w *TrackingResponseWriter) WriteHeader(statusCode int) {
+	w.setTraceHeaders()
 	w.gatherHeaders()
 	w.track.responseStatus = statusCode
 	w.rw.WriteHeader(statusCode)
@@ -77,6 +105,7 @@ func (w *TrackingResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 }
 
 func (w *TrackingResponseWriter) Flush() {
+	w.setTraceHeaders()
 	if w.flusher != nil {
 		w.flusher.Flush()
 	}
@@ -2,26 +2,44 @@ package server
 
 import (
 	"context"
+	"crypto/subtle"
 	"net"
 	"net/http"
 	"net/textproto"
 
+	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/trace"
 
+	"github.com/krakend/krakend-otel/config"
 	"github.com/krakend/krakend-otel/state"
 )
 
 type trackingHandler struct {
 	next http.Handler
 
-	prop          propagation.TextMapPropagator
-	metrics       *metricsHTTP
-	traces        *tracesHTTP
+	instruments   *state.Instruments[*handlerInstruments]
 	reportHeaders bool
 	skipHeaders   map[string]bool
 	config        state.Config
+	forceSampling *config.ForceSamplingOpts
+
+	trustContext   string
+	trustedSources []*net.IPNet
+
+	traceResponse bool
+	traceIDHeader string
+}
+
+// handlerInstruments are the parts of the handler that depend on the
+// [state.OTEL] instance, so they can be replaced after a reload.
+type handlerInstruments struct {
+	state   state.OTEL
+	prop    propagation.TextMapPropagator
+	metrics *metricsHTTP
+	traces  *tracesHTTP
+	logs    *logsHTTP
 }
 
 func (h *trackingHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
@@ -30,32 +48,68 @@ func (h *trackingHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	hi := h.instruments.Get()
 	t := newTracking()
 	t.ctx = r.Context()
-	if h.prop != nil {
-		t.ctx = h.prop.Extract(t.ctx, propagation.HeaderCarrier(r.Header))
-		if t.ctx != r.Context() {
-			t.span = trace.SpanFromContext(t.ctx)
+	if hi.prop != nil {
+		if h.trustsContext(r) {
+			t.ctx = hi.prop.Extract(t.ctx, propagation.HeaderCarrier(r.Header))
+			if t.ctx != r.Context() {
+				t.span = trace.SpanFromContext(t.ctx)
+			}
+		} else {
+			// the incoming context is only linked from a new
+			// trace, and its baggage is dropped
+			extracted := hi.prop.Extract(t.ctx, propagation.HeaderCarrier(r.Header))
+			t.link = trace.SpanContextFromContext(extracted)
+		}
+	}
+	if h.forceSampling != nil {
+		if forcedSampling(h.forceSampling, r) {
+			t.ctx = state.ContextWithForceSampling(t.ctx, h.forceSampling.Header)
 		}
 	}
 	t.ctx = context.WithValue(t.ctx, krakenDContextTrackingStrKey, t)
 	r = r.WithContext(t.ctx)
 
-	if h.metrics != nil || h.traces != nil {
-		rw = newTrackingResponseWriter(rw, t, h.reportHeaders, h.skipHeaders, func(c net.Conn, _ error) (net.Conn, error) {
+	var trw *TrackingResponseWriter
+	if hi.metrics != nil || hi.traces != nil || hi.logs != nil || h.traceResponse || h.traceIDHeader != "" {
+		trw = newTrackingResponseWriter(rw, t, h.reportHeaders, h.skipHeaders, func(c net.Conn, _ error) (net.Conn, error) {
 			t.Finish()
-			h.traces.end(t)
-			h.metrics.report(t, r)
+			h.report(hi, t, r)
 			return c, nil
 		})
+		trw.traceResponse = h.traceResponse
+		trw.traceIDHeader = h.traceIDHeader
+		rw = trw
 	}
 
 	t.Start()
-	r = h.traces.start(r, t)
+	r = hi.traces.start(r, t)
 	h.next.ServeHTTP(rw, r)
+	if trw != nil {
+		// when nothing has been written, the headers are
+		// sent after returning
+		trw.setTraceHeaders()
+	}
 	t.Finish()
-	h.traces.end(t)
-	h.metrics.report(t, r)
+	h.report(hi, t, r)
+}
+
+// report ends the server span and reports the metrics and logs. When
+// the matched endpoint selects its own exporters, the span and the
+// metrics are reported to them (the logs always use the global ones).
+func (h *trackingHandler) report(hi *handlerInstruments, t *tracking, r *http.Request) {
+	metrics := hi.metrics
+	if t.endpointOTEL != nil && t.endpointOTEL != hi.state {
+		if hi.traces != nil {
+			state.RouteSpan(hi.state, t.endpointOTEL, t.span)
+		}
+		metrics = h.instruments.For(t.endpointOTEL).metrics
+	}
+	hi.traces.end(t)
+	metrics.report(t, r)
+	hi.logs.report(t, r)
 }
 
 func NewTrackingHandler(next http.Handler) http.Handler {
@@ -69,55 +123,138 @@ func NewTrackingHandlerWithTrustedProxies(next http.Handler, trustedProxies []st
 	}
 
 	gCfg := otelCfg.GlobalOpts()
-	if gCfg.DisablePropagation && gCfg.DisableMetrics && gCfg.DisableTraces {
+	if gCfg.DisablePropagation && gCfg.DisableMetrics && gCfg.DisableTraces && gCfg.DisableLogs {
 		return next
 	}
-	s := otelCfg.OTEL()
-	var prop propagation.TextMapPropagator
-	if !gCfg.DisablePropagation {
-		prop = s.Propagator()
-	}
-
-	var m *metricsHTTP
-	if !gCfg.DisableMetrics {
-		var metricsAttrs []attribute.KeyValue
-		for _, kv := range gCfg.MetricsStaticAttributes {
-			if kv.Key != "" && kv.Value != "" {
-				metricsAttrs = append(metricsAttrs, attribute.String(kv.Key, kv.Value))
-			}
+	var metricsAttrs []attribute.KeyValue
+	for _, kv := range gCfg.MetricsStaticAttributes {
+		if kv.Key != "" && kv.Value != "" {
+			metricsAttrs = append(metricsAttrs, attribute.String(kv.Key, kv.Value))
 		}
-
-		m = newMetricsHTTP(s.Meter(), metricsAttrs, gCfg.SemConv)
 	}
 
 	var sh map[string]bool
 	if len(gCfg.SkipHeaders) > 0 {
-		sh = make(map[string]bool, len(gCfg.SkipHeaders))
+		sh = make(map[string]bool, len(gCfg.SkipHeaders)+1)
 		for _, v := range gCfg.SkipHeaders {
 			canonical := textproto.CanonicalMIMEHeaderKey(v)
 			sh[canonical] = true
 		}
 	}
+	// the header to force the sampling can carry a secret,
+	// so it is never reported
+	if gCfg.ForceSampling != nil && gCfg.ForceSampling.Header != "" {
+		if sh == nil {
+			sh = make(map[string]bool, 1)
+		}
+		sh[textproto.CanonicalMIMEHeaderKey(gCfg.ForceSampling.Header)] = true
+	}
 
-	var t *tracesHTTP
-	if !gCfg.DisableTraces {
-		tracesAttrs := []attribute.KeyValue{attribute.String("krakend.stage", "global")}
-		for _, kv := range gCfg.TracesStaticAttributes {
-			if kv.Key != "" && kv.Value != "" {
-				tracesAttrs = append(tracesAttrs, attribute.String(kv.Key, kv.Value))
-			}
+	tracesAttrs := []attribute.KeyValue{attribute.String("krakend.stage", "global")}
+	for _, kv := range gCfg.TracesStaticAttributes {
+		if kv.Key != "" && kv.Value != "" {
+			tracesAttrs = append(tracesAttrs, attribute.String(kv.Key, kv.Value))
 		}
+	}
+
+	// the propagators to extract the incoming context can be
+	// different from the ones used to inject it into the backends
+	extractProp, err := state.NewPropagator(gCfg.Propagators)
+	if err != nil {
+		otel.Handle(err)
+	}
 
-		t = newTracesHTTP(s.Tracer(), tracesAttrs, gCfg.ReportHeaders, sh, trustedProxies)
+	// the trusted proxies are also trusted to send the trace context
+	trustedSources, err := config.ParseCIDRs(gCfg.TrustedContextSources)
+	if err != nil {
+		otel.Handle(err)
+	}
+	for _, tp := range trustedProxies {
+		if n, err := config.ParseCIDRs([]string{tp}); err == nil {
+			trustedSources = append(trustedSources, n...)
+		}
+	}
+
+	// the state is resolved at request time, to use the
+	// new one after a reload:
+	build := func(s state.OTEL) *handlerInstruments {
+		hi := &handlerInstruments{state: s}
+		if s == nil {
+			return hi
+		}
+		if !gCfg.DisablePropagation {
+			hi.prop = extractProp
+			if hi.prop == nil {
+				hi.prop = s.Propagator()
+			}
+		}
+		if !gCfg.DisableMetrics {
+			hi.metrics = newMetricsHTTP(s.Meter(), metricsAttrs, gCfg.SemConv)
+			hi.metrics.baggage = state.BaggageAttributesFromConfig(otelCfg)
+		}
+		if !gCfg.DisableTraces {
+			hi.traces = newTracesHTTP(s.Tracer(), tracesAttrs, gCfg.ReportHeaders, sh, trustedProxies)
+		}
+		if !gCfg.DisableLogs {
+			if logger := state.Logger(s); logger != nil {
+				hi.logs = &logsHTTP{logger: logger}
+			}
+		}
+		return hi
 	}
 
 	return &trackingHandler{
 		next:          next,
-		prop:          prop,
-		metrics:       m,
-		traces:        t,
+		instruments:   state.NewInstruments(state.GlobalConfigOTEL, build),
 		reportHeaders: gCfg.ReportHeaders,
 		skipHeaders:   sh,
 		config:        otelCfg,
+		forceSampling: gCfg.ForceSampling,
+
+		trustContext:   gCfg.TrustIncomingContext,
+		trustedSources: trustedSources,
+
+		traceResponse: gCfg.TraceResponse,
+		traceIDHeader: gCfg.TraceIDHeader,
+	}
+}
+
+// trustsContext tells if the trace context of the request
+// has to be continued.
+func (h *trackingHandler) trustsContext(r *http.Request) bool {
+	switch h.trustContext {
+	case config.TrustContextNever:
+		return false
+	case config.TrustContextTrusted:
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		ip := net.ParseIP(host)
+		if ip == nil {
+			return false
+		}
+		for _, n := range h.trustedSources {
+			if n.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// forcedSampling checks if the request asks to force its sampling.
+func forcedSampling(opts *config.ForceSamplingOpts, r *http.Request) bool {
+	var v string
+	if opts.Header != "" {
+		v = r.Header.Get(opts.Header)
+	}
+	if v == "" && opts.QueryParam != "" && r.URL != nil {
+		v = r.URL.Query().Get(opts.QueryParam)
+	}
+	if v == "" {
+		return false
 	}
+	return opts.Secret == "" || subtle.ConstantTimeCompare([]byte(v), []byte(opts.Secret)) == 1
 }
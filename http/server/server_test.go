@@ -0,0 +1,275 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	sdktracetest "go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/krakend/krakend-otel/config"
+	"github.com/krakend/krakend-otel/exporter"
+	"github.com/krakend/krakend-otel/state"
+)
+
+func TestTrackingHandler_forceSampling(t *testing.T) {
+	var forced bool
+	var header string
+	next := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		header, forced = state.ForceSampling(r.Context())
+		rw.WriteHeader(http.StatusOK)
+	})
+	h := &trackingHandler{
+		next: next,
+		instruments: state.NewInstruments(func() state.OTEL { return nil },
+			func(state.OTEL) *handlerInstruments { return &handlerInstruments{} }),
+		config:        state.NewConfig(&config.ConfigData{}),
+		forceSampling: &config.ForceSamplingOpts{Header: "X-Debug-Trace", QueryParam: "debug_trace", Secret: "s3cr3t"},
+	}
+
+	for _, tc := range []struct {
+		name     string
+		url      string
+		header   string
+		expected bool
+	}{
+		{"none", "/users/42", "", false},
+		{"header", "/users/42", "s3cr3t", true},
+		{"bad_secret", "/users/42", "guess", false},
+		{"query", "/users/42?debug_trace=s3cr3t", "", true},
+	} {
+		req := httptest.NewRequest(http.MethodGet, tc.url, http.NoBody)
+		if tc.header != "" {
+			req.Header.Set("X-Debug-Trace", tc.header)
+		}
+		h.ServeHTTP(httptest.NewRecorder(), req)
+		if forced != tc.expected {
+			t.Errorf("%s: expected forced %v", tc.name, tc.expected)
+			continue
+		}
+		if forced && header != "X-Debug-Trace" {
+			t.Errorf("%s: unexpected header to propagate %s", tc.name, header)
+		}
+	}
+}
+
+func TestTrackingHandler_trustIncomingContext(t *testing.T) {
+	remoteTraceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+	prop := propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+	for _, tc := range []struct {
+		name       string
+		policy     string
+		remoteAddr string
+		trusted    bool
+	}{
+		{"default", "", "203.0.113.7:1234", true},
+		{"never", config.TrustContextNever, "10.0.0.1:1234", false},
+		{"trusted_source", config.TrustContextTrusted, "10.1.2.3:1234", true},
+		{"trusted_proxy", config.TrustContextTrusted, "192.168.1.1:1234", true},
+		{"untrusted_source", config.TrustContextTrusted, "203.0.113.7:1234", false},
+	} {
+		spanRecorder := sdktracetest.NewSpanRecorder()
+		tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spanRecorder))
+		var members int
+		next := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			members = baggage.FromContext(r.Context()).Len()
+			rw.WriteHeader(http.StatusOK)
+		})
+		hi := &handlerInstruments{
+			prop:   prop,
+			traces: newTracesHTTP(tracerProvider.Tracer("test"), nil, false, nil, nil),
+		}
+		trustedSources, _ := config.ParseCIDRs([]string{"10.0.0.0/8", "192.168.1.1"})
+		h := &trackingHandler{
+			next: next,
+			instruments: state.NewInstruments(func() state.OTEL { return nil },
+				func(state.OTEL) *handlerInstruments { return hi }),
+			config:         state.NewConfig(&config.ConfigData{}),
+			trustContext:   tc.policy,
+			trustedSources: trustedSources,
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/users/42", http.NoBody)
+		req.RemoteAddr = tc.remoteAddr
+		req.Header.Set("Traceparent", "00-"+remoteTraceID+"-00f067aa0ba902b7-01")
+		req.Header.Set("Baggage", "tenant=acme")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+
+		spans := spanRecorder.Ended()
+		if len(spans) != 1 {
+			t.Errorf("%s: expected 1 span, got %d", tc.name, len(spans))
+			continue
+		}
+		continued := spans[0].SpanContext().TraceID().String() == remoteTraceID
+		if continued != tc.trusted {
+			t.Errorf("%s: expected the trace to be continued: %v", tc.name, tc.trusted)
+		}
+		if tc.trusted {
+			if members != 1 {
+				t.Errorf("%s: expected the baggage to be kept", tc.name)
+			}
+			continue
+		}
+		if members != 0 {
+			t.Errorf("%s: expected the baggage to be dropped", tc.name)
+		}
+		links := spans[0].Links()
+		if len(links) != 1 || links[0].SpanContext.TraceID().String() != remoteTraceID {
+			t.Errorf("%s: expected a link to the incoming context: %v", tc.name, links)
+		}
+	}
+}
+
+func TestTrackingHandler_traceResponseHeaders(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		next http.HandlerFunc
+	}{
+		{"write_header", func(rw http.ResponseWriter, _ *http.Request) { rw.WriteHeader(http.StatusCreated) }},
+		{"write", func(rw http.ResponseWriter, _ *http.Request) { rw.Write([]byte("ok")) }},
+		{"nothing_written", func(http.ResponseWriter, *http.Request) {}},
+	} {
+		spanRecorder := sdktracetest.NewSpanRecorder()
+		tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spanRecorder))
+		hi := &handlerInstruments{
+			traces: newTracesHTTP(tracerProvider.Tracer("test"), nil, false, nil, nil),
+		}
+		h := &trackingHandler{
+			next: tc.next,
+			instruments: state.NewInstruments(func() state.OTEL { return nil },
+				func(state.OTEL) *handlerInstruments { return hi }),
+			config:        state.NewConfig(&config.ConfigData{}),
+			traceResponse: true,
+			traceIDHeader: "X-Trace-Id",
+		}
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/42", http.NoBody))
+
+		spans := spanRecorder.Ended()
+		if len(spans) != 1 {
+			t.Errorf("%s: expected 1 span, got %d", tc.name, len(spans))
+			continue
+		}
+		sc := spans[0].SpanContext()
+		expected := "00-" + sc.TraceID().String() + "-" + sc.SpanID().String() + "-01"
+		if got := w.Result().Header.Get("traceresponse"); got != expected {
+			t.Errorf("%s: expected traceresponse %s, got %q", tc.name, expected, got)
+		}
+		if got := w.Result().Header.Get("X-Trace-Id"); got != sc.TraceID().String() {
+			t.Errorf("%s: unexpected trace id header %q", tc.name, got)
+		}
+	}
+}
+
+type testExporters struct {
+	spans  *sdktracetest.InMemoryExporter
+	reader *sdkmetric.ManualReader
+}
+
+func (e *testExporters) SpanExporter() sdktrace.SpanExporter { return e.spans }
+func (*testExporters) TraceDefaultReporting() bool           { return true }
+func (*testExporters) BatchOpts() *config.BatchOpts          { return &config.BatchOpts{Synchronous: true} }
+
+func (e *testExporters) MetricReader(time.Duration) sdkmetric.Reader { return e.reader }
+func (*testExporters) MetricDefaultReporting() bool                  { return true }
+
+func (e *testExporters) metricNames(t *testing.T) map[string]bool {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := e.reader.Collect(context.Background(), &rm); err != nil {
+		t.Errorf("cannot collect the metrics: %s", err.Error())
+	}
+	names := map[string]bool{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			names[m.Name] = true
+		}
+	}
+	return names
+}
+
+func newTestExportersState(t *testing.T) (*state.OTELState, *testExporters) {
+	t.Helper()
+	exps := &testExporters{
+		spans:  sdktracetest.NewInMemoryExporter(),
+		reader: sdkmetric.NewManualReader(),
+	}
+	s, err := state.NewWithVersion("test", &state.OTELStateConfig{
+		MetricProviders:       []string{"test"},
+		TraceProviders:        []string{"test"},
+		MetricReportingPeriod: 30,
+		TraceSampleRate:       1.0,
+	}, "v0.0.0", map[string]exporter.MetricReader{"test": exps},
+		map[string]exporter.SpanExporter{"test": exps})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	return s, exps
+}
+
+func TestTrackingHandler_endpointSelectedExporters(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		selected bool
+		notSet   bool
+	}{
+		{"global", false, false},
+		{"selected", true, false},
+		// the routers that do not set the endpoint state
+		{"not_set", true, true},
+	} {
+		global, globalExps := newTestExportersState(t)
+		selected, selectedExps := newTestExportersState(t)
+		endpoint, expected, other := global, globalExps, selectedExps
+		if tc.selected {
+			endpoint = selected
+			if !tc.notSet {
+				expected, other = selectedExps, globalExps
+			}
+		}
+
+		next := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			if !tc.notSet {
+				SetEndpointOTEL(r.Context(), endpoint)
+			}
+			rw.WriteHeader(http.StatusOK)
+		})
+		h := &trackingHandler{
+			next: next,
+			instruments: state.NewInstruments(func() state.OTEL { return global },
+				func(s state.OTEL) *handlerInstruments {
+					return &handlerInstruments{
+						state:   s,
+						metrics: newMetricsHTTP(s.Meter(), nil, ""),
+						traces:  newTracesHTTP(s.Tracer(), nil, false, nil, nil),
+					}
+				}),
+			config: state.NewConfig(&config.ConfigData{}),
+		}
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/42", http.NoBody))
+
+		if n := len(expected.spans.GetSpans()); n != 1 {
+			t.Errorf("%s: expected the server span in the endpoint exporter, got %d spans", tc.name, n)
+		}
+		if n := len(other.spans.GetSpans()); n != 0 {
+			t.Errorf("%s: unexpected %d spans in the other exporter", tc.name, n)
+		}
+		if !expected.metricNames(t)["http.server.duration"] {
+			t.Errorf("%s: expected the server metrics in the endpoint exporter", tc.name)
+		}
+		if other.metricNames(t)["http.server.duration"] {
+			t.Errorf("%s: unexpected server metrics in the other exporter", tc.name)
+		}
+		global.Shutdown(context.Background())
+		selected.Shutdown(context.Background())
+	}
+}
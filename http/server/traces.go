@@ -49,8 +49,17 @@ func (t *tracesHTTP) start(r *http.Request, tr *tracking) *http.Request {
 	if t == nil || t.tracer == nil || r.URL == nil {
 		return r
 	}
-	tr.ctx, tr.span = t.tracer.Start(r.Context(), r.URL.Path,
-		trace.WithSpanKind(trace.SpanKindServer))
+	// the route has not been matched yet, so we provide the method and
+	// path to let the sampler find the endpoint
+	opts := []trace.SpanStartOption{
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(semconv.HTTPRequestMethodKey.String(r.Method),
+			semconv.URLPath(r.URL.Path)),
+	}
+	if tr.link.IsValid() {
+		opts = append(opts, trace.WithLinks(trace.Link{SpanContext: tr.link}))
+	}
+	tr.ctx, tr.span = t.tracer.Start(r.Context(), r.URL.Path, opts...)
 	r = r.WithContext(tr.ctx)
 
 	attrs := otelhttp.TraceIncomingRequestAttrs(r, t.trustedProxies)
@@ -8,6 +8,8 @@ import (
 
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
+
+	"github.com/krakend/krakend-otel/state"
 )
 
 type KrakenDContextTrackingTypeKey string
@@ -23,6 +25,8 @@ type tracking struct {
 	startTime time.Time
 	ctx       context.Context
 	span      trace.Span
+	// link is the incoming context that is not trusted
+	link trace.SpanContext
 
 	latencyInSecs      float64
 	responseSize       int
@@ -34,6 +38,8 @@ type tracking struct {
 	metricsStaticAttrs []attribute.KeyValue
 	tracesStaticAttrs  []attribute.KeyValue
 	hijackedErr        error
+	// endpointOTEL is the state selected by the matched endpoint
+	endpointOTEL state.OTEL
 }
 
 func (t *tracking) EndpointPattern() string {
@@ -86,6 +92,15 @@ func SetStaticAttributtes(ctx context.Context, metricAttrs, tracesAttrs []attrib
 	}
 }
 
+// SetEndpointOTEL allows to set the state selected by the endpoint
+// once it has been matched, so the server span and metrics are reported
+// to its exporters (see [config.ExporterSelection]).
+func SetEndpointOTEL(ctx context.Context, s state.OTEL) {
+	if t := fromContext(ctx); t != nil {
+		t.endpointOTEL = s
+	}
+}
+
 func (t *tracking) Start() {
 	t.startTime = time.Now()
 }
@@ -0,0 +1,158 @@
+// Package logging provides a Lura logger that correlates the log
+// messages with the traces.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/luraproject/lura/v2/logging"
+
+	"github.com/krakend/krakend-otel/state"
+)
+
+// fatalFlushTimeout is the max time to wait for the FATAL
+// record to be exported before the process exits.
+const fatalFlushTimeout = 2 * time.Second
+
+var _ logging.Logger = (*Logger)(nil)
+
+// Logger wraps a Lura [logging.Logger] adding the "trace_id" and
+// "span_id" of the current span to every message.
+//
+// Since the Lura logger interface has no context, the span is taken from
+// the context bound with [Logger.WithContext], or from a [context.Context]
+// passed as one of the values to log (that is removed from the message).
+//
+// When forward is enabled, the messages are also emitted as log records
+// with the logger of the global OTEL state, so they can be exported with
+// the configured logs exporters.
+//
+// The wrapper is opt-in, as registering the telemetry does not install
+// it: the service must wrap its logger with [NewLogger] and use the
+// returned one (that can also be the one passed to the registration).
+type Logger struct {
+	l       logging.Logger
+	ctx     context.Context
+	forward bool
+	otel    state.GetterFn
+}
+
+// NewLogger wraps the provided logger.
+func NewLogger(l logging.Logger, forward bool) *Logger {
+	return &Logger{
+		l:       l,
+		forward: forward,
+		otel:    state.GlobalState,
+	}
+}
+
+// WithContext returns a copy of the logger that uses the span
+// in the provided context.
+func (l *Logger) WithContext(ctx context.Context) *Logger {
+	c := *l
+	c.ctx = ctx
+	return &c
+}
+
+func (l *Logger) Debug(v ...interface{}) {
+	l.l.Debug(l.process(log.SeverityDebug, "DEBUG", v)...)
+}
+
+func (l *Logger) Info(v ...interface{}) {
+	l.l.Info(l.process(log.SeverityInfo, "INFO", v)...)
+}
+
+func (l *Logger) Warning(v ...interface{}) {
+	l.l.Warning(l.process(log.SeverityWarn, "WARNING", v)...)
+}
+
+func (l *Logger) Error(v ...interface{}) {
+	l.l.Error(l.process(log.SeverityError, "ERROR", v)...)
+}
+
+func (l *Logger) Critical(v ...interface{}) {
+	l.l.Critical(l.process(log.SeverityError4, "CRITICAL", v)...)
+}
+
+func (l *Logger) Fatal(v ...interface{}) {
+	// the record is forwarded and flushed before calling the
+	// wrapped logger, because it exits the process.
+	values := l.process(log.SeverityFatal, "FATAL", v)
+	if l.forward {
+		l.flush()
+	}
+	l.l.Fatal(values...)
+}
+
+// flush exports the pending records of the logger provider
+// of the global OTEL state, when it supports it.
+func (l *Logger) flush() {
+	if l.otel == nil {
+		return
+	}
+	ls, ok := l.otel().(state.LogsOTEL)
+	if !ok {
+		return
+	}
+	f, ok := ls.LoggerProvider().(interface{ ForceFlush(context.Context) error })
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), fatalFlushTimeout)
+	defer cancel()
+	f.ForceFlush(ctx)
+}
+
+// process extracts the context from the values, forwards the message
+// (when enabled) and returns the values to log with the trace ids.
+func (l *Logger) process(severity log.Severity, severityText string, v []interface{}) []interface{} {
+	ctx := l.ctx
+	values := make([]interface{}, 0, len(v)+2)
+	for _, val := range v {
+		if c, ok := val.(context.Context); ok {
+			ctx = c
+			continue
+		}
+		values = append(values, val)
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if l.forward {
+		l.emit(ctx, severity, severityText, values)
+	}
+
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return values
+	}
+	return append(values, "trace_id="+sc.TraceID().String(), "span_id="+sc.SpanID().String())
+}
+
+func (l *Logger) emit(ctx context.Context, severity log.Severity, severityText string, values []interface{}) {
+	if l.otel == nil {
+		return
+	}
+	s := l.otel()
+	if s == nil {
+		return
+	}
+	logger := state.Logger(s)
+	if logger == nil || !logger.Enabled(ctx, log.EnabledParameters{Severity: severity}) {
+		return
+	}
+
+	var rec log.Record
+	rec.SetTimestamp(time.Now())
+	rec.SetSeverity(severity)
+	rec.SetSeverityText(severityText)
+	rec.SetBody(log.StringValue(strings.TrimSuffix(fmt.Sprintln(values...), "\n")))
+	logger.Emit(ctx, rec)
+}
@@ -0,0 +1,166 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+
+	"github.com/krakend/krakend-otel/exporter"
+	"github.com/krakend/krakend-otel/state"
+)
+
+type testLuraLogger struct {
+	lines []string
+}
+
+func (l *testLuraLogger) log(level string, v ...interface{}) {
+	l.lines = append(l.lines, level+" "+strings.TrimSuffix(fmt.Sprintln(v...), "\n"))
+}
+
+func (l *testLuraLogger) Debug(v ...interface{})    { l.log("DEBUG", v...) }
+func (l *testLuraLogger) Info(v ...interface{})     { l.log("INFO", v...) }
+func (l *testLuraLogger) Warning(v ...interface{})  { l.log("WARNING", v...) }
+func (l *testLuraLogger) Error(v ...interface{})    { l.log("ERROR", v...) }
+func (l *testLuraLogger) Critical(v ...interface{}) { l.log("CRITICAL", v...) }
+func (l *testLuraLogger) Fatal(v ...interface{})    { l.log("FATAL", v...) }
+
+type testLogExporter struct {
+	mu      sync.Mutex
+	records []sdklog.Record
+}
+
+func (e *testLogExporter) LogExporter() sdklog.Exporter { return e }
+func (*testLogExporter) LogDefaultReporting() bool      { return true }
+
+func (e *testLogExporter) Export(_ context.Context, records []sdklog.Record) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, r := range records {
+		e.records = append(e.records, r.Clone())
+	}
+	return nil
+}
+
+func (*testLogExporter) Shutdown(context.Context) error   { return nil }
+func (*testLogExporter) ForceFlush(context.Context) error { return nil }
+
+func TestLogger_traceIDs(t *testing.T) {
+	tp := sdktrace.NewTracerProvider()
+	ctx, span := tp.Tracer("test").Start(context.Background(), "test-span")
+	defer span.End()
+	sc := span.SpanContext()
+	ids := "trace_id=" + sc.TraceID().String() + " span_id=" + sc.SpanID().String()
+
+	inner := &testLuraLogger{}
+	l := NewLogger(inner, false)
+
+	l.Info("no context")
+	l.Error("context as a value", ctx)
+	l.WithContext(ctx).Debug("bound context")
+	l.WithContext(context.Background()).Warning("no span")
+
+	expected := []string{
+		"INFO no context",
+		"ERROR context as a value " + ids,
+		"DEBUG bound context " + ids,
+		"WARNING no span",
+	}
+	if len(inner.lines) != len(expected) {
+		t.Errorf("expected %d lines, got %d: %v", len(expected), len(inner.lines), inner.lines)
+		return
+	}
+	for i, want := range expected {
+		if inner.lines[i] != want {
+			t.Errorf("line %d: expected %q, got %q", i, want, inner.lines[i])
+		}
+	}
+}
+
+func TestLogger_forward(t *testing.T) {
+	le := &testLogExporter{}
+	s, err := state.NewWithLogs("test", &state.OTELStateConfig{LogProviders: []string{"test"}},
+		"v0.0.0", "", nil, nil, map[string]exporter.LogExporter{"test": le})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+
+	tp := sdktrace.NewTracerProvider()
+	ctx, span := tp.Tracer("test").Start(context.Background(), "test-span")
+	span.End()
+
+	inner := &testLuraLogger{}
+	l := NewLogger(inner, true)
+	l.otel = func() state.OTEL { return s }
+
+	l.WithContext(ctx).Warning("[SERVICE: test]", "something happened")
+	s.Shutdown(context.Background())
+
+	le.mu.Lock()
+	defer le.mu.Unlock()
+	if len(le.records) != 1 {
+		t.Errorf("expected 1 forwarded record, got %d", len(le.records))
+		return
+	}
+	rec := le.records[0]
+	if got := rec.Body().AsString(); got != "[SERVICE: test] something happened" {
+		t.Errorf("unexpected body: %q", got)
+	}
+	if rec.Severity() != log.SeverityWarn || rec.SeverityText() != "WARNING" {
+		t.Errorf("unexpected severity: %s (%s)", rec.Severity(), rec.SeverityText())
+	}
+	if rec.TraceID() != span.SpanContext().TraceID() || rec.SpanID() != span.SpanContext().SpanID() {
+		t.Errorf("the record is not correlated with the span")
+	}
+	if len(inner.lines) != 1 {
+		t.Errorf("expected the message to be also logged by the wrapped logger")
+	}
+}
+
+func TestLogger_forwardWithoutState(t *testing.T) {
+	inner := &testLuraLogger{}
+	l := NewLogger(inner, true)
+	l.otel = func() state.OTEL { return nil }
+	l.Info("no state")
+	if len(inner.lines) != 1 || inner.lines[0] != "INFO no state" {
+		t.Errorf("unexpected lines: %v", inner.lines)
+	}
+}
+
+func TestLogger_fatal(t *testing.T) {
+	le := &testLogExporter{}
+	s, err := state.NewWithLogs("test", &state.OTELStateConfig{LogProviders: []string{"test"}},
+		"v0.0.0", "", nil, nil, map[string]exporter.LogExporter{"test": le})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	defer s.Shutdown(context.Background())
+
+	inner := &testLuraLogger{}
+	l := NewLogger(inner, true)
+	l.otel = func() state.OTEL { return s }
+
+	l.Critical("critical")
+	// the records are flushed before the wrapped logger exits
+	l.Fatal("fatal")
+
+	le.mu.Lock()
+	defer le.mu.Unlock()
+	if len(le.records) != 2 {
+		t.Errorf("expected 2 flushed records, got %d", len(le.records))
+		return
+	}
+	if sev := le.records[0].Severity(); sev != log.SeverityError4 {
+		t.Errorf("unexpected critical severity: %s", sev)
+	}
+	if sev := le.records[1].Severity(); sev != log.SeverityFatal {
+		t.Errorf("unexpected fatal severity: %s", sev)
+	}
+}
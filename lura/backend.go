@@ -2,8 +2,10 @@ package lura
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 
+	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/semconv/v1.21.0"
 
@@ -59,10 +61,13 @@ func InstrumentedHTTPClientFactory(clientFactory transport.HTTPClientFactory,
 
 	opts := otelCfg.BackendOpts(cfg)
 	if !opts.Enabled() {
-		return clientFactory
+		// the client is still wrapped to never send the
+		// header that forced the sampling of the request
+		return func(ctx context.Context) *http.Client {
+			return clienthttp.InstrumentedHTTPClient(clientFactory(ctx),
+				&clienthttp.TransportOptions{}, cfg.URLPattern)
+		}
 	}
-	otelState := otelCfg.BackendOTEL(cfg)
-
 	// this might not be necessary:
 	if opts.Metrics == nil {
 		opts.Metrics = defaultOpts.Metrics
@@ -98,6 +103,14 @@ func InstrumentedHTTPClientFactory(clientFactory transport.HTTPClientFactory,
 		}
 	}
 
+	// an invalid override is reported, and the propagators
+	// from the state are used
+	propagator, err := otelstate.NewPropagator(opts.Traces.Propagators)
+	if err != nil {
+		otel.Handle(fmt.Errorf("bad telemetry propagators for backend %s of endpoint %s: %s",
+			cfg.URLPattern, cfg.ParentEndpoint, err.Error()))
+	}
+
 	t := clienthttp.TransportOptions{
 		MetricsOpts: clienthttp.TransportMetricsOptions{
 			RoundTrip:          opts.Metrics.RoundTrip,
@@ -105,6 +118,7 @@ func InstrumentedHTTPClientFactory(clientFactory transport.HTTPClientFactory,
 			DetailedConnection: opts.Metrics.DetailedConnection,
 			FixedAttributes:    metricAttrs,
 			SemConv:            strictSemConv,
+			BaggageAttributes:  otelstate.BaggageAttributesFromConfig(otelCfg),
 		},
 		TracesOpts: clienthttp.TransportTracesOptions{
 			RoundTrip:          opts.Traces.RoundTrip,
@@ -113,8 +127,12 @@ func InstrumentedHTTPClientFactory(clientFactory transport.HTTPClientFactory,
 			FixedAttributes:    traceAttrs,
 			ReportHeaders:      opts.Traces.ReportHeaders,
 			SkipHeaders:        opts.Traces.SkipHeaders,
+			DisablePropagation: opts.Traces.DisablePropagation,
+			Propagator:         propagator,
 		},
-		OTELInstance: otelState,
+		// the state is resolved for each request, to use the
+		// new one after a reload:
+		OTELGetter: otelstate.BackendOTELGetter(cfg),
 	}
 
 	return func(ctx context.Context) *http.Client {
@@ -2,10 +2,14 @@ package lura
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"time"
 
+	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/luraproject/lura/v2/config"
 	"github.com/luraproject/lura/v2/proxy"
@@ -14,59 +18,66 @@ import (
 	"github.com/krakend/krakend-otel/state"
 )
 
-func tracesMiddleware(next proxy.Proxy, mt *middlewareTracer) func(ctx context.Context, req *proxy.Request) (*proxy.Response, error) {
-	return func(ctx context.Context, req *proxy.Request) (*proxy.Response, error) {
-		ctx, span := mt.start(ctx, req)
-		resp, err := next(ctx, req)
-		mt.end(span, resp, err)
-		return resp, err
-	}
-}
-
-func metricsMiddleware(next proxy.Proxy, mm *middlewareMeter) func(ctx context.Context, req *proxy.Request) (*proxy.Response, error) {
-	return func(ctx context.Context, req *proxy.Request) (*proxy.Response, error) {
-		startedAt := time.Now()
-		resp, err := next(ctx, req)
-		durationInSecs := float64(time.Since(startedAt)) / float64(time.Second)
-		mm.report(ctx, durationInSecs, resp, err)
-		return resp, err
-	}
+// stageInstruments are the parts of a stage middleware that depend
+// on the [state.OTEL] instance, so they can be replaced after a reload.
+type stageInstruments struct {
+	meter  *middlewareMeter
+	tracer *middlewareTracer
 }
 
-func metricsAndTracesMiddleware(next proxy.Proxy, mm *middlewareMeter, mt *middlewareTracer) func(ctx context.Context, req *proxy.Request) (*proxy.Response, error) {
+func instrumentedProxy(next proxy.Proxy, instruments *state.Instruments[stageInstruments]) proxy.Proxy {
 	return func(ctx context.Context, req *proxy.Request) (*proxy.Response, error) {
-		ctx, span := mt.start(ctx, req)
+		si := instruments.Get()
+		var span trace.Span
+		if si.tracer != nil {
+			ctx, span = si.tracer.start(ctx, req)
+		}
 		startedAt := time.Now()
 		resp, err := next(ctx, req)
-		durationInSecs := float64(time.Since(startedAt)) / float64(time.Second)
-		mm.report(ctx, durationInSecs, resp, err)
-		mt.end(span, resp, err)
+		if si.meter != nil {
+			durationInSecs := float64(time.Since(startedAt)) / float64(time.Second)
+			si.meter.report(ctx, durationInSecs, resp, err)
+		}
+		if si.tracer != nil {
+			si.tracer.end(span, resp, err)
+		}
 		return resp, err
 	}
 }
 
 // middleware creates a proxy that instruments the proxy it wraps by creating an span if enabled,
 // and report the duration of this stage in metrics if enabled.
-func middleware(gs state.OTEL, metricsEnabled bool, tracesEnabled bool,
+//
+// The [state.OTEL] instance is obtained from the getter at request time, so
+// the instruments are created again when the state changes.
+func middleware(gs state.GetterFn, metricsEnabled bool, tracesEnabled bool,
 	stageName string, urlPattern string, metricsAttrs, tracesAttrs []attribute.KeyValue,
 	reportHeaders bool, skipHeaders []string,
 ) proxy.Middleware {
-	var mt *middlewareTracer
-	var mm *middlewareMeter
-	var err error
-	if metricsEnabled {
-		mm, err = newMiddlewareMeter(gs, stageName, metricsAttrs)
-		if err != nil {
-			// TODO: log the error
-			metricsEnabled = false
+	build := func(s state.OTEL) stageInstruments {
+		var si stageInstruments
+		if s == nil {
+			return si
 		}
-	}
-	if tracesEnabled {
-		mt = newMiddlewareTracer(gs, urlPattern, stageName, reportHeaders, skipHeaders, tracesAttrs)
-		if mt == nil {
-			// TODO: log the error
-			tracesEnabled = false
+		// the stage is not instrumented when its meter or tracer
+		// cannot be created, and the error is reported
+		if metricsEnabled {
+			mm, err := newMiddlewareMeter(s, stageName, metricsAttrs)
+			if err != nil {
+				otel.Handle(fmt.Errorf("cannot create the %s meter for %s: %s",
+					stageName, urlPattern, err.Error()))
+			} else {
+				si.meter = mm
+			}
+		}
+		if tracesEnabled {
+			si.tracer = newMiddlewareTracer(s, urlPattern, stageName, reportHeaders, skipHeaders, tracesAttrs)
+			if si.tracer == nil {
+				otel.Handle(fmt.Errorf("cannot create the %s tracer for %s: the state returned a nil tracer",
+					stageName, urlPattern))
+			}
 		}
+		return si
 	}
 
 	return func(next ...proxy.Proxy) proxy.Proxy {
@@ -78,15 +89,10 @@ func middleware(gs state.OTEL, metricsEnabled bool, tracesEnabled bool,
 		}
 		n := next[0]
 
-		if metricsEnabled {
-			if tracesEnabled {
-				return metricsAndTracesMiddleware(n, mm, mt)
-			}
-			return metricsMiddleware(n, mm)
-		} else if tracesEnabled {
-			return tracesMiddleware(n, mt)
+		if !metricsEnabled && !tracesEnabled {
+			return n
 		}
-		return n
+		return instrumentedProxy(n, state.NewInstruments(gs, build))
 	}
 }
 
@@ -108,13 +114,45 @@ func ProxyFactory(pf proxy.Factory) proxy.FactoryFunc {
 			return next, nil
 		}
 
+		// the selected exporters are only resolved at request time,
+		// so they are checked for the endpoint and its backends here,
+		// like the sampling config of the backends (as the backend
+		// factory cannot return an error)
+		if err := state.ValidateExporterSelection(otelCfg, cfg.ExtraConfig); err != nil {
+			return next, fmt.Errorf("bad telemetry exporters for endpoint %s: %s",
+				cfg.Endpoint, err.Error())
+		}
+		for _, b := range cfg.Backend {
+			if err := state.ValidateExporterSelection(otelCfg, b.ExtraConfig); err != nil {
+				return next, fmt.Errorf("bad telemetry exporters for backend %s of endpoint %s: %s",
+					b.URLPattern, cfg.Endpoint, err.Error())
+			}
+			if _, err := kotelconfig.LuraSamplingExtraCfg(b.ExtraConfig); err != nil &&
+				!errors.Is(err, kotelconfig.ErrNoConfig) {
+				return next, fmt.Errorf("bad telemetry sampling config for backend %s of endpoint %s: %s",
+					b.URLPattern, cfg.Endpoint, err.Error())
+			}
+		}
+
+		urlPattern := kotelconfig.NormalizeURLPattern(cfg.Endpoint)
+		// the sample rate is also used for the server span, so it is
+		// set even if the stage is not instrumented
+		samplingOpts, err := kotelconfig.LuraSamplingExtraCfg(cfg.ExtraConfig)
+		if err != nil && !errors.Is(err, kotelconfig.ErrNoConfig) {
+			return next, fmt.Errorf("bad telemetry sampling config for endpoint %s: %s",
+				cfg.Endpoint, err.Error())
+		}
+		if rates := state.SampleRatesFromConfig(otelCfg); rates != nil && samplingOpts != nil &&
+			samplingOpts.SampleRate != nil {
+			rates.SetEndpointSampleRate(cfg.Method, urlPattern, *samplingOpts.SampleRate)
+		}
+
 		pipeOpts := otelCfg.EndpointPipeOpts(cfg)
 		if pipeOpts.DisableMetrics && pipeOpts.DisableTraces {
 			return next, nil
 		}
 
-		gs := otelCfg.EndpointOTEL(cfg)
-		urlPattern := kotelconfig.NormalizeURLPattern(cfg.Endpoint)
+		gs := state.EndpointOTELGetter(cfg)
 		attrs := []attribute.KeyValue{
 			semconv.HTTPRequestMethodKey.String(cfg.Method),
 			semconv.HTTPRoute(urlPattern),
@@ -154,6 +192,20 @@ func BackendFactory(bf proxy.BackendFactory) proxy.BackendFactory {
 		if otelCfg.SkipEndpoint(cfg.ParentEndpoint) {
 			return next
 		}
+		urlPattern := kotelconfig.NormalizeURLPattern(cfg.URLPattern)
+		parentEndpoint := kotelconfig.NormalizeURLPattern(cfg.ParentEndpoint)
+
+		// the sample rate is also used for the round trip spans, so it
+		// is set even if the stage is not instrumented (a bad sampling
+		// config is returned as an error by the [ProxyFactory])
+		samplingOpts, err := kotelconfig.LuraSamplingExtraCfg(cfg.ExtraConfig)
+		if err == nil && samplingOpts.SampleRate != nil {
+			if rates := state.SampleRatesFromConfig(otelCfg); rates != nil {
+				rates.SetBackendSampleRate(cfg.ParentEndpointMethod, parentEndpoint, cfg.Method,
+					urlPattern, *samplingOpts.SampleRate)
+			}
+		}
+
 		backendOpts := otelCfg.BackendOpts(cfg)
 		metricsDisabled := backendOpts != nil && backendOpts.Metrics != nil && backendOpts.Metrics.DisableStage
 		tracesDisabled := backendOpts != nil && backendOpts.Traces != nil && backendOpts.Traces.DisableStage
@@ -161,9 +213,7 @@ func BackendFactory(bf proxy.BackendFactory) proxy.BackendFactory {
 			return next
 		}
 
-		gs := otelCfg.BackendOTEL(cfg)
-		urlPattern := kotelconfig.NormalizeURLPattern(cfg.URLPattern)
-		parentEndpoint := kotelconfig.NormalizeURLPattern(cfg.ParentEndpoint)
+		gs := state.BackendOTELGetter(cfg)
 		attrs := []attribute.KeyValue{
 			semconv.HTTPRequestMethodKey.String(cfg.Method),
 			semconv.HTTPRoute(urlPattern), // <- for traces we can use URLFull to not have the matched path
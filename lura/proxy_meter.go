@@ -16,6 +16,7 @@ import (
 type middlewareMeter struct {
 	duration metric.Float64Histogram
 	attrs    metric.MeasurementOption
+	baggage  *state.BaggageAttributes
 }
 
 func newMiddlewareMeter(s state.OTEL, stageName string, attrs []attribute.KeyValue) (*middlewareMeter, error) {
@@ -38,6 +39,7 @@ func newMiddlewareMeter(s state.OTEL, stageName string, attrs []attribute.KeyVal
 	return &middlewareMeter{
 		duration: duration,
 		attrs:    metric.WithAttributes(mAttrs...),
+		baggage:  state.BaggageAttributesFromConfig(state.GlobalConfig()),
 	}, nil
 }
 
@@ -73,5 +75,9 @@ func (m *middlewareMeter) report(ctx context.Context, secs float64, resp *proxy.
 		attribute.Bool("error", isErr),
 		attribute.Bool("canceled", isCanceled),
 		attribute.Bool("complete", resp != nil && resp.IsComplete))
+	if bagAttrs := m.baggage.MetricAttributes(ctx); len(bagAttrs) > 0 {
+		m.duration.Record(ctx, secs, m.attrs, metricDynAttrs, metric.WithAttributes(bagAttrs...))
+		return
+	}
 	m.duration.Record(ctx, secs, m.attrs, metricDynAttrs)
 }
@@ -51,8 +51,9 @@ func newMiddlewareTracer(s state.OTEL, name string, stageName string, reportHead
 }
 
 func (t *middlewareTracer) start(ctx context.Context, req *proxy.Request) (context.Context, trace.Span) {
-	ctx, span := t.tracer.Start(ctx, t.name)
-	span.SetAttributes(t.attrs...)
+	// the attributes are provided at start, so the sampler
+	// can use the route of the endpoint or backend
+	ctx, span := t.tracer.Start(ctx, t.name, trace.WithAttributes(t.attrs...))
 	if t.reportHeaders {
 		for hk, hv := range req.Headers {
 			if t.skipHeaders == nil || !t.skipHeaders[hk] {
@@ -17,6 +17,7 @@ import (
 
 	"go.opentelemetry.io/contrib/propagators/autoprop"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
 
 	lconfig "github.com/luraproject/lura/v2/config"
 	lcore "github.com/luraproject/lura/v2/core"
@@ -51,17 +52,38 @@ func RegisterWithConfig(ctx context.Context, l logging.Logger, cfg *config.Confi
 		return shutdownFn, err
 	}
 
-	me, te, err := exporter.Instances(ctx, cfg)
+	// the exporters get their own context, so they can be stopped
+	// when replaced by a Reload
+	exportersCtx, cancel := context.WithCancel(ctx)
+	me, te, le, err := exporter.InstancesWithLogs(exportersCtx, cfg)
 	if err != nil {
+		cancel()
+		return shutdownFn, err
+	}
+	prop, err := state.NewPropagator(cfg.Propagators)
+	if err != nil {
+		cancel()
 		return shutdownFn, err
 	}
 	exporter.SetGlobalExporterInstances(me, te)
-	shutdown, err := RegisterGlobalInstanceWithEnv(ctx, l, me, te, *cfg.MetricReportingPeriod,
-		*cfg.TraceSampleRate, cfg.ServiceName, cfg.ServiceVersion, cfg.DeployEnv)
-	if err == nil {
-		state.SetGlobalConfig(state.NewConfig(cfg))
+	exporter.SetGlobalLogExporterInstances(le)
+	setGlobalHandlers(l, prop)
+	stateCfg := state.NewConfigWithExporters(cfg, me, te)
+	s, err := newGlobalState(me, te, le, baseStateConfig(cfg, stateCfg.SampleRates()),
+		cfg.ServiceName, cfg.ServiceVersion, cfg.DeployEnv)
+	if err != nil {
+		cancel()
+		return shutdownFn, err
 	}
-	return shutdown, err
+	state.SetGlobalState(s)
+	state.SetGlobalConfig(stateCfg)
+	setRegistration(&registration{
+		ctx:    ctx,
+		cancel: cancel,
+		state:  s,
+		config: stateCfg,
+	})
+	return func() { shutdownRegistration(ctx) }, nil
 }
 
 // RegisterGlobalInstance creates the instance that will be used to report metrics and traces
@@ -80,11 +102,23 @@ func RegisterGlobalInstanceWithEnv(ctx context.Context, l logging.Logger,
 	env string,
 ) (func(), error) {
 	shutdownFn := func() {}
+	setGlobalHandlers(l, nil)
+	s, err := newGlobalState(me, te, nil, state.OTELStateConfig{
+		MetricReportingPeriod: metricReportingPeriod,
+		TraceSampleRate:       traceSampleRate,
+	}, serviceName, serviceVersion, env)
+	if err != nil {
+		return shutdownFn, err
+	}
+	shutdownFn = func() { s.Shutdown(ctx) }
+	state.SetGlobalState(s)
+	return shutdownFn, nil
+}
 
-	// Create a propagator that defaults to propagating the W3C Trace Context and Baggage headers
-	// but allows for other propagators to be enabled via the OTEL_PROPAGATORS env variable.
-	prop := autoprop.NewTextMapPropagator()
-	otel.SetTextMapPropagator(prop)
+// setGlobalHandlers sets the global propagation method and the handler
+// for the errors reported by the otel library.
+func setGlobalHandlers(l logging.Logger, prop propagation.TextMapPropagator) {
+	setGlobalPropagator(prop)
 
 	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(e error) {
 		// TODO: we might want to "throtle" the error reporting
@@ -92,13 +126,45 @@ func RegisterGlobalInstanceWithEnv(ctx context.Context, l logging.Logger,
 		// down.
 		l.Error("[SERVICE: OpenTelemetry] " + e.Error())
 	}))
+}
 
-	globalStateCfg := &state.OTELStateConfig{
-		MetricReportingPeriod: metricReportingPeriod,
-		TraceSampleRate:       traceSampleRate,
-		MetricProviders:       make([]string, 0, len(me)),
-		TraceProviders:        make([]string, 0, len(te)),
+// setGlobalPropagator sets the configured propagator as the global one,
+// or, when there is none, a propagator that defaults to propagating the
+// W3C Trace Context and Baggage headers but allows for other propagators
+// to be enabled via the OTEL_PROPAGATORS env variable.
+func setGlobalPropagator(prop propagation.TextMapPropagator) {
+	if prop == nil {
+		prop = autoprop.NewTextMapPropagator()
+	}
+	otel.SetTextMapPropagator(prop)
+}
+
+// baseStateConfig returns the settings of the configuration that
+// are shared by all the states (the ones that are not the exporters).
+func baseStateConfig(cfg *config.ConfigData, rates *state.SampleRates) state.OTELStateConfig {
+	return state.OTELStateConfig{
+		MetricReportingPeriod: *cfg.MetricReportingPeriod,
+		TraceSampleRate:       *cfg.TraceSampleRate,
+		Sampler:               cfg.Sampler,
+		RemoteSampling:        cfg.RemoteSampling,
+		Propagators:           cfg.Propagators,
+		Resource:              cfg.Resource,
+		TailSampling:          cfg.TailSampling,
+		SampleRates:           rates,
+		BaggageAttributes:     cfg.GlobalBaggageAttributes(),
 	}
+}
+
+// newGlobalState creates the state to be used as the global one, with
+// the base settings and the exporters that report by default.
+func newGlobalState(me map[string]exporter.MetricReader, te map[string]exporter.SpanExporter,
+	le map[string]exporter.LogExporter, base state.OTELStateConfig,
+	serviceName string, serviceVersion string, env string,
+) (*state.OTELState, error) {
+	globalStateCfg := &base
+	globalStateCfg.MetricProviders = make([]string, 0, len(me))
+	globalStateCfg.TraceProviders = make([]string, 0, len(te))
+	globalStateCfg.LogProviders = make([]string, 0, len(le))
 	for k, v := range me {
 		if v.MetricDefaultReporting() {
 			globalStateCfg.MetricProviders = append(globalStateCfg.MetricProviders, k)
@@ -109,17 +175,16 @@ func RegisterGlobalInstanceWithEnv(ctx context.Context, l logging.Logger,
 			globalStateCfg.TraceProviders = append(globalStateCfg.TraceProviders, k)
 		}
 	}
+	for k, v := range le {
+		if v.LogDefaultReporting() {
+			globalStateCfg.LogProviders = append(globalStateCfg.LogProviders, k)
+		}
+	}
 
 	version := serviceVersion
 	if version == "" {
 		version = lcore.KrakendVersion
 	}
 
-	s, err := state.NewWithVersionAndEnv(serviceName, globalStateCfg, version, env, me, te)
-	if err != nil {
-		return shutdownFn, err
-	}
-	shutdownFn = func() { s.Shutdown(ctx) }
-	state.SetGlobalState(s)
-	return shutdownFn, nil
+	return state.NewWithLogs(serviceName, globalStateCfg, version, env, me, te, le)
 }
@@ -0,0 +1,123 @@
+package kotel
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/krakend/krakend-otel/config"
+	"github.com/krakend/krakend-otel/exporter"
+	"github.com/krakend/krakend-otel/state"
+)
+
+// ErrNotRegistered is returned when trying to reload the configuration
+// before it has been registered with [Register] or [RegisterWithConfig].
+var ErrNotRegistered = errors.New("the telemetry configuration has not been registered")
+
+// registration keeps what is needed to replace the
+// global state and its exporters.
+type registration struct {
+	// ctx is the context provided when registering, that is
+	// the parent of the context for each set of exporters
+	ctx    context.Context
+	cancel context.CancelFunc
+	state  *state.OTELState
+	// config has the states for the endpoints and
+	// backends that select their own exporters
+	config *state.StateConfig
+}
+
+// shutdown flushes all the states before shutting them down, as
+// they share the exporters, and stops the exporters.
+func (r *registration) shutdown(ctx context.Context) {
+	r.state.ForceFlush(ctx)
+	r.config.ForceFlush(ctx)
+	r.config.Shutdown(ctx)
+	r.state.Shutdown(ctx)
+	r.cancel()
+}
+
+var (
+	currentRegistration *registration
+	registrationMu      sync.Mutex
+)
+
+func setRegistration(r *registration) {
+	registrationMu.Lock()
+	currentRegistration = r
+	registrationMu.Unlock()
+}
+
+func shutdownRegistration(ctx context.Context) {
+	registrationMu.Lock()
+	r := currentRegistration
+	currentRegistration = nil
+	registrationMu.Unlock()
+	if r == nil {
+		return
+	}
+	r.shutdown(ctx)
+}
+
+// Reload replaces the global state, configuration and exporters with
+// new ones created from the provided configuration, without having to
+// restart the service: the instrumented handlers, proxies and
+// transports get the state for each request, so they start using the
+// new one once it has been swapped.
+//
+// The options for each layer (enabled metrics and traces, static
+// attributes ...) are read when the handlers are created, so
+// changing them still requires a restart.
+//
+// The previous state is flushed and shut down using the provided
+// context. If the new configuration is not valid, or an exporter
+// cannot be created, the previous state keeps being used.
+func Reload(ctx context.Context, cfg *config.ConfigData) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	cfg.UnsetFieldsToDefaults()
+
+	registrationMu.Lock()
+	defer registrationMu.Unlock()
+	prev := currentRegistration
+	if prev == nil {
+		return ErrNotRegistered
+	}
+
+	prop, err := state.NewPropagator(cfg.Propagators)
+	if err != nil {
+		return err
+	}
+
+	exportersCtx, cancel := context.WithCancel(prev.ctx)
+	me, te, le, err := exporter.InstancesWithLogs(exportersCtx, cfg)
+	if err != nil {
+		cancel()
+		return err
+	}
+	// the sample rates of the endpoints and backends are set when
+	// building the pipeline, so the ones in use must be kept
+	stateCfg := state.NewConfigWithExportersAndSampleRates(cfg, me, te, prev.config.SampleRates())
+	s, err := newGlobalState(me, te, le, baseStateConfig(cfg, stateCfg.SampleRates()),
+		cfg.ServiceName, cfg.ServiceVersion, cfg.DeployEnv)
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	exporter.SetGlobalExporterInstances(me, te)
+	exporter.SetGlobalLogExporterInstances(le)
+	setGlobalPropagator(prop)
+	state.SetGlobalState(s)
+	state.SetGlobalConfig(stateCfg)
+	currentRegistration = &registration{
+		ctx:    prev.ctx,
+		cancel: cancel,
+		state:  s,
+		config: stateCfg,
+	}
+
+	prev.shutdown(ctx)
+	return nil
+}
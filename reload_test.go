@@ -0,0 +1,149 @@
+package kotel
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luraproject/lura/v2/logging"
+
+	"github.com/krakend/krakend-otel/config"
+	kotelserver "github.com/krakend/krakend-otel/http/server"
+	"github.com/krakend/krakend-otel/state"
+)
+
+func freePort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot get a free port: %s", err.Error())
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func scrape(url string) (string, error) {
+	var lastErr error
+	for i := 0; i < 50; i++ {
+		resp, err := http.Get(url)
+		if err == nil {
+			b, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			return string(b), err
+		}
+		lastErr = err
+		time.Sleep(20 * time.Millisecond)
+	}
+	return "", lastErr
+}
+
+func TestReload(t *testing.T) {
+	port := freePort(t)
+	metricsURL := fmt.Sprintf("http://127.0.0.1:%d/metrics", port)
+	newCfg := func(serviceName string) *config.ConfigData {
+		return &config.ConfigData{
+			ServiceName: serviceName,
+			Exporters: config.Exporters{
+				Prometheus: []config.PrometheusExporter{
+					{Name: "prom", Host: "127.0.0.1", Port: port},
+				},
+			},
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cfg := newCfg("before-reload")
+	cfg.UnsetFieldsToDefaults()
+	shutdown, err := RegisterWithConfig(ctx, logging.NoOp, cfg)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	defer shutdown()
+	prevState := state.GlobalState()
+	rates := state.SampleRatesFromConfig(state.GlobalConfig())
+	if rates == nil {
+		t.Errorf("missing the sample rates of the config")
+		return
+	}
+
+	h := kotelserver.NewTrackingHandler(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/foo", http.NoBody))
+
+	body, err := scrape(metricsURL)
+	if err != nil {
+		t.Errorf("cannot scrape the metrics: %s", err.Error())
+		return
+	}
+	if !strings.Contains(body, `service_name="before-reload"`) {
+		t.Errorf("missing the service name before the reload:\n%s", body)
+	}
+
+	if err := Reload(context.Background(), newCfg("after-reload")); err != nil {
+		t.Errorf("unexpected error reloading: %s", err.Error())
+		return
+	}
+	if state.GlobalState() == prevState {
+		t.Errorf("the global state has not been replaced")
+	}
+	// the rates set when building the pipeline are kept
+	if state.SampleRatesFromConfig(state.GlobalConfig()) != rates {
+		t.Errorf("the sample rates have not been kept")
+	}
+
+	// the handler created before the reload must use the new state
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/foo", http.NoBody))
+	body, err = scrape(metricsURL)
+	if err != nil {
+		t.Errorf("cannot scrape the metrics after the reload: %s", err.Error())
+		return
+	}
+	if !strings.Contains(body, `service_name="after-reload"`) || strings.Contains(body, "before-reload") {
+		t.Errorf("the metrics are not reported with the new state:\n%s", body)
+	}
+	if !strings.Contains(body, "http_server_duration") {
+		t.Errorf("the handler metrics are not reported with the new state:\n%s", body)
+	}
+}
+
+func TestReload_badConfig(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cfg := &config.ConfigData{ServiceName: "svc"}
+	cfg.UnsetFieldsToDefaults()
+	shutdown, err := RegisterWithConfig(ctx, logging.NoOp, cfg)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	defer shutdown()
+	prevState := state.GlobalState()
+
+	badCfg := &config.ConfigData{
+		Exporters: config.Exporters{
+			Custom: []config.CustomExporter{{Name: "custom", Type: "unknown"}},
+		},
+	}
+	if err := Reload(context.Background(), badCfg); err == nil {
+		t.Errorf("expected an error for an unknown exporter type")
+	}
+	if state.GlobalState() != prevState {
+		t.Errorf("the global state must be kept when the reload fails")
+	}
+}
+
+func TestReload_notRegistered(t *testing.T) {
+	shutdownRegistration(context.Background())
+	if err := Reload(context.Background(), &config.ConfigData{}); err != ErrNotRegistered {
+		t.Errorf("expected ErrNotRegistered, got %v", err)
+	}
+}
@@ -23,6 +23,7 @@ func New(hf krakendgin.HandlerFactory) krakendgin.HandlerFactory {
 			return hf(cfg, p)
 		}
 		urlPattern := kotelconfig.NormalizeURLPattern(cfg.Endpoint)
+		getOTEL := otelstate.EndpointOTELGetter(cfg)
 		next := hf(cfg, p)
 		var metricsAttrs []attribute.KeyValue
 		var tracesAttrs []attribute.KeyValue
@@ -48,6 +49,9 @@ func New(hf krakendgin.HandlerFactory) krakendgin.HandlerFactory {
 			// in metrics and traces.
 			kotelserver.SetEndpointPattern(c.Request.Context(), urlPattern)
 			kotelserver.SetStaticAttributtes(c.Request.Context(), metricsAttrs, tracesAttrs)
+			// the server span and metrics go to the exporters
+			// selected by the endpoint
+			kotelserver.SetEndpointOTEL(c.Request.Context(), getOTEL())
 			next(c)
 		}
 	}
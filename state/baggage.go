@@ -0,0 +1,140 @@
+package state
+
+import (
+	"context"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+
+	"github.com/krakend/krakend-otel/config"
+)
+
+const (
+	defaultBaggageMaxValuesPerKey = 100
+	baggageOtherValue             = "_other"
+	// baggageAttributePrefix is prepended to the keys, so a client
+	// cannot replace the standard attributes sending them as baggage
+	baggageAttributePrefix = "baggage."
+)
+
+// BaggageAttributes promotes the values of some baggage keys to
+// span and metric attributes, named "baggage.<key>".
+type BaggageAttributes struct {
+	keys      []string
+	metrics   bool
+	maxValues int
+
+	mu   sync.RWMutex
+	seen map[string]map[string]struct{}
+}
+
+// NewBaggageAttributes creates the [BaggageAttributes] for the options,
+// or returns nil when there are no keys to promote.
+func NewBaggageAttributes(opts *config.BaggageAttributesOpts) *BaggageAttributes {
+	if opts == nil || len(opts.Keys) == 0 {
+		return nil
+	}
+	b := &BaggageAttributes{
+		keys:      opts.Keys,
+		metrics:   opts.Metrics,
+		maxValues: opts.MaxValuesPerKey,
+		seen:      make(map[string]map[string]struct{}, len(opts.Keys)),
+	}
+	if b.maxValues == 0 {
+		b.maxValues = defaultBaggageMaxValuesPerKey
+	}
+	// the seen values are kept by attribute name
+	for _, k := range opts.Keys {
+		b.seen[baggageAttributePrefix+k] = map[string]struct{}{}
+	}
+	return b
+}
+
+// SpanAttributes returns the attributes for the baggage in the context.
+func (b *BaggageAttributes) SpanAttributes(ctx context.Context) []attribute.KeyValue {
+	if b == nil {
+		return nil
+	}
+	bag := baggage.FromContext(ctx)
+	if bag.Len() == 0 {
+		return nil
+	}
+	var attrs []attribute.KeyValue
+	for _, k := range b.keys {
+		if m := bag.Member(k); m.Key() != "" {
+			attrs = append(attrs, attribute.String(baggageAttributePrefix+k, m.Value()))
+		}
+	}
+	return attrs
+}
+
+// MetricAttributes returns the attributes for the baggage in the context
+// when the metrics are enabled, with the values over the limit for
+// each key replaced by "_other".
+func (b *BaggageAttributes) MetricAttributes(ctx context.Context) []attribute.KeyValue {
+	if b == nil || !b.metrics {
+		return nil
+	}
+	attrs := b.SpanAttributes(ctx)
+	for i, kv := range attrs {
+		attrs[i] = attribute.String(string(kv.Key), b.guard(string(kv.Key), kv.Value.AsString()))
+	}
+	return attrs
+}
+
+func (b *BaggageAttributes) guard(key, value string) string {
+	b.mu.RLock()
+	_, ok := b.seen[key][value]
+	full := len(b.seen[key]) >= b.maxValues
+	b.mu.RUnlock()
+	if ok {
+		return value
+	}
+	if full {
+		return baggageOtherValue
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.seen[key][value]; ok {
+		return value
+	}
+	if len(b.seen[key]) >= b.maxValues {
+		return baggageOtherValue
+	}
+	b.seen[key][value] = struct{}{}
+	return value
+}
+
+// baggageSpanProcessor sets the baggage attributes to the spans
+// when they start.
+type baggageSpanProcessor struct {
+	attrs *BaggageAttributes
+}
+
+func (p *baggageSpanProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	if attrs := p.attrs.SpanAttributes(parent); len(attrs) > 0 {
+		s.SetAttributes(attrs...)
+	}
+}
+
+func (*baggageSpanProcessor) OnEnd(sdktrace.ReadOnlySpan)      {}
+func (*baggageSpanProcessor) Shutdown(context.Context) error   { return nil }
+func (*baggageSpanProcessor) ForceFlush(context.Context) error { return nil }
+
+// BaggageConfig is implemented by the [Config] instances that
+// promote baggage members to metric attributes.
+type BaggageConfig interface {
+	BaggageAttributes() *BaggageAttributes
+}
+
+// BaggageAttributesFromConfig returns the [BaggageAttributes] of the
+// config, or nil if the config does not implement [BaggageConfig].
+func BaggageAttributesFromConfig(c Config) *BaggageAttributes {
+	if bc, ok := c.(BaggageConfig); ok {
+		return bc.BaggageAttributes()
+	}
+	return nil
+}
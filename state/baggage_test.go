@@ -0,0 +1,88 @@
+package state
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	sdktracetest "go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/krakend/krakend-otel/config"
+)
+
+func contextWithBaggage(t *testing.T, s string) context.Context {
+	t.Helper()
+	bag, err := baggage.Parse(s)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	return baggage.ContextWithBaggage(context.Background(), bag)
+}
+
+func TestBaggageAttributes_spans(t *testing.T) {
+	if b := NewBaggageAttributes(&config.BaggageAttributesOpts{}); b != nil {
+		t.Errorf("expected no baggage attributes without keys")
+		return
+	}
+	b := NewBaggageAttributes(&config.BaggageAttributesOpts{Keys: []string{"tenant", "app"}})
+	recorder := sdktracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(&baggageSpanProcessor{attrs: b}),
+		sdktrace.WithSpanProcessor(recorder))
+
+	ctx := contextWithBaggage(t, "tenant=acme,user=alice")
+	_, span := tp.Tracer("test").Start(ctx, "span")
+	span.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Errorf("expected 1 span, got %d", len(spans))
+		return
+	}
+	set := attribute.NewSet(spans[0].Attributes()...)
+	if v, ok := set.Value("baggage.tenant"); !ok || v.AsString() != "acme" {
+		t.Errorf("unexpected tenant attribute: %v", v)
+	}
+	if _, ok := set.Value("baggage.user"); ok {
+		t.Errorf("unexpected user attribute: the key is not in the list")
+	}
+	if attrs := b.MetricAttributes(ctx); len(attrs) != 0 {
+		t.Errorf("unexpected metric attributes with metrics disabled: %v", attrs)
+	}
+}
+
+func TestBaggageAttributes_metricsCardinality(t *testing.T) {
+	b := NewBaggageAttributes(&config.BaggageAttributesOpts{
+		Keys:            []string{"tenant"},
+		Metrics:         true,
+		MaxValuesPerKey: 2,
+	})
+	for _, tc := range []struct {
+		tenant   string
+		expected string
+	}{
+		{"a", "a"},
+		{"b", "b"},
+		{"c", baggageOtherValue},
+		{"a", "a"},
+	} {
+		attrs := b.MetricAttributes(contextWithBaggage(t, "tenant="+tc.tenant))
+		if len(attrs) != 1 || attrs[0].Value.AsString() != tc.expected {
+			t.Errorf("tenant %s: expected %s, got %v", tc.tenant, tc.expected, attrs)
+		}
+	}
+}
+
+func TestBaggageAttributes_prefixed(t *testing.T) {
+	b := NewBaggageAttributes(&config.BaggageAttributesOpts{
+		Keys:    []string{"http.route"},
+		Metrics: true,
+	})
+	// a client cannot replace the route dimension of the metrics
+	attrs := b.MetricAttributes(contextWithBaggage(t, "http.route=/fake"))
+	if len(attrs) != 1 || attrs[0].Key != "baggage.http.route" || attrs[0].Value.AsString() != "/fake" {
+		t.Errorf("unexpected metric attributes: %v", attrs)
+	}
+}
@@ -1,7 +1,10 @@
 package state
 
 import (
+	"context"
+
 	"github.com/krakend/krakend-otel/config"
+	"github.com/krakend/krakend-otel/exporter"
 	luraconfig "github.com/luraproject/lura/v2/config"
 )
 
@@ -30,10 +33,18 @@ type Config interface {
 	SkipEndpoint(endpoint string) bool
 }
 
-var _ Config = (*StateConfig)(nil)
+var (
+	_ Config                  = (*StateConfig)(nil)
+	_ BaggageConfig           = (*StateConfig)(nil)
+	_ SampleRatesConfig       = (*StateConfig)(nil)
+	_ ExporterSelectionConfig = (*StateConfig)(nil)
+)
 
 type StateConfig struct {
-	cfgData config.ConfigData
+	cfgData     config.ConfigData
+	selected    *selectedStates
+	baggage     *BaggageAttributes
+	sampleRates *SampleRates
 }
 
 func (*StateConfig) OTEL() OTEL {
@@ -44,8 +55,23 @@ func (s *StateConfig) GlobalOpts() *config.GlobalOpts {
 	return s.cfgData.Layers.Global
 }
 
-func (*StateConfig) EndpointOTEL(_ *luraconfig.EndpointConfig) OTEL {
-	return GlobalState()
+// EndpointOTEL returns the state for the exporters selected in the
+// endpoint config, or the global state when it uses the default ones
+// (see [config.ExporterSelection]).
+func (s *StateConfig) EndpointOTEL(cfg *luraconfig.EndpointConfig) OTEL {
+	if s == nil || s.selected == nil || cfg == nil {
+		return GlobalState()
+	}
+	return s.selected.forConfig(cfg, cfg.ExtraConfig)
+}
+
+// ValidateExporterSelection checks that the exporters selected in
+// the extra config of an endpoint or backend have been created.
+func (s *StateConfig) ValidateExporterSelection(extraCfg luraconfig.ExtraConfig) error {
+	if s == nil || s.selected == nil {
+		return nil
+	}
+	return s.selected.validate(extraCfg)
 }
 
 // EndpointPipeOpts checks if there is an override for pipe ("proxy")
@@ -76,8 +102,14 @@ func (s *StateConfig) EndpointBackendOpts(cfg *luraconfig.Backend) *config.Backe
 	return s.mergedBackendOpts(cfg)
 }
 
-func (*StateConfig) BackendOTEL(_ *luraconfig.Backend) OTEL {
-	return GlobalState()
+// BackendOTEL returns the state for the exporters selected in the
+// backend config, or the global state when it uses the default ones
+// (see [config.ExporterSelection]).
+func (s *StateConfig) BackendOTEL(cfg *luraconfig.Backend) OTEL {
+	if s == nil || s.selected == nil || cfg == nil {
+		return GlobalState()
+	}
+	return s.selected.forConfig(cfg, cfg.ExtraConfig)
 }
 
 func (s *StateConfig) BackendOpts(cfg *luraconfig.Backend) *config.BackendOpts {
@@ -115,5 +147,73 @@ func NewConfig(cfgData *config.ConfigData) *StateConfig {
 		cfgData: *cfgData,
 	}
 	s.cfgData.UnsetFieldsToDefaults()
+	s.baggage = NewBaggageAttributes(s.cfgData.GlobalBaggageAttributes())
+	s.sampleRates = NewSampleRates()
 	return s
 }
+
+// BaggageAttributes returns the baggage members to promote to
+// attributes, shared by all the layers (so the cardinality limit
+// applies to all of them).
+func (s *StateConfig) BaggageAttributes() *BaggageAttributes {
+	if s == nil {
+		return nil
+	}
+	return s.baggage
+}
+
+// SampleRates returns the sample rates of the endpoints and backends,
+// to be used by the samplers of all the states of the config.
+func (s *StateConfig) SampleRates() *SampleRates {
+	if s == nil {
+		return nil
+	}
+	return s.sampleRates
+}
+
+// NewConfigWithExporters creates a config that can create the states
+// for the endpoints and backends that select their own exporters from
+// the provided ones.
+//
+// Those states must be flushed and shut down with [StateConfig.ForceFlush]
+// and [StateConfig.Shutdown].
+func NewConfigWithExporters(cfgData *config.ConfigData, me map[string]exporter.MetricReader,
+	te map[string]exporter.SpanExporter,
+) *StateConfig {
+	return NewConfigWithExportersAndSampleRates(cfgData, me, te, nil)
+}
+
+// NewConfigWithExportersAndSampleRates creates a config like
+// [NewConfigWithExporters] that keeps using the provided sample rates
+// (usually, the ones of the config it replaces), or new ones when nil.
+func NewConfigWithExportersAndSampleRates(cfgData *config.ConfigData, me map[string]exporter.MetricReader,
+	te map[string]exporter.SpanExporter, rates *SampleRates,
+) *StateConfig {
+	s := NewConfig(cfgData)
+	if rates != nil {
+		s.sampleRates = rates
+	}
+	s.selected = newSelectedStates(&s.cfgData, me, te, s.sampleRates)
+	return s
+}
+
+// ForceFlush flushes the pending telemetry of the states created for
+// the endpoints and backends that select their own exporters.
+func (s *StateConfig) ForceFlush(ctx context.Context) {
+	if s == nil || s.selected == nil {
+		return
+	}
+	s.selected.forceFlush(ctx)
+}
+
+// Shutdown shuts down the states created for the endpoints and backends
+// that select their own exporters.
+//
+// As the exporters are shared with other states, all of them should be
+// flushed before shutting down any of them.
+func (s *StateConfig) Shutdown(ctx context.Context) {
+	if s == nil || s.selected == nil {
+		return
+	}
+	s.selected.shutdown(ctx)
+}
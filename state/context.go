@@ -1 +1,33 @@
 package state
+
+import (
+	"context"
+)
+
+// ForcedSamplingValue is the value of the header that propagates a
+// forced sampling to the backends. It is a fixed marker, so the secret
+// used to force the sampling never leaves the gateway.
+const ForcedSamplingValue = "1"
+
+type forceSamplingCtxKey struct{}
+
+// forcedSampling has the header (if any) that forced the sampling
+// of a request, so it can be propagated.
+type forcedSampling struct {
+	header string
+}
+
+// ContextWithForceSampling returns a context that makes the sampler
+// record and sample all the spans started with it. The header, when
+// not empty, is the one to propagate to the backends with the
+// [ForcedSamplingValue].
+func ContextWithForceSampling(ctx context.Context, header string) context.Context {
+	return context.WithValue(ctx, forceSamplingCtxKey{}, forcedSampling{header: header})
+}
+
+// ForceSampling tells if the sampling has been forced for the context,
+// and returns the header to propagate it (that can be empty).
+func ForceSampling(ctx context.Context) (header string, forced bool) {
+	fs, ok := ctx.Value(forceSamplingCtxKey{}).(forcedSampling)
+	return fs.header, ok
+}
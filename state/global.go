@@ -2,6 +2,8 @@ package state
 
 import (
 	"sync"
+
+	luraconfig "github.com/luraproject/lura/v2/config"
 )
 
 var (
@@ -44,3 +46,39 @@ func GlobalConfig() Config {
 	globalConfigMutex.RUnlock()
 	return c
 }
+
+// GlobalConfigOTEL returns the [OTEL] instance of the global config
+// at the time it is called.
+func GlobalConfigOTEL() OTEL {
+	c := GlobalConfig()
+	if c == nil {
+		return nil
+	}
+	return c.OTEL()
+}
+
+// EndpointOTELGetter returns a getter for the [OTEL] instance of an
+// endpoint, that is resolved with the global config at the time it
+// is called.
+func EndpointOTELGetter(cfg *luraconfig.EndpointConfig) GetterFn {
+	return func() OTEL {
+		c := GlobalConfig()
+		if c == nil {
+			return nil
+		}
+		return c.EndpointOTEL(cfg)
+	}
+}
+
+// BackendOTELGetter returns a getter for the [OTEL] instance of a
+// backend, that is resolved with the global config at the time it
+// is called.
+func BackendOTELGetter(cfg *luraconfig.Backend) GetterFn {
+	return func() OTEL {
+		c := GlobalConfig()
+		if c == nil {
+			return nil
+		}
+		return c.BackendOTEL(cfg)
+	}
+}
@@ -0,0 +1,92 @@
+package state
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Instruments holds values created from an [OTEL] instance (tracers,
+// metric instruments ...) that is obtained at request time, so the
+// instrumented components pick up a new global state after a reload.
+//
+// The values are cached, and only built again when the getter returns
+// a different instance.
+type Instruments[T any] struct {
+	getter GetterFn
+	build  func(OTEL) T
+
+	mu  sync.Mutex
+	cur atomic.Pointer[instrumentsEntry[T]]
+
+	// others has the values for other instances than the one from
+	// the getter (see [Instruments.For]), that are dropped when the
+	// getter returns a new instance, like after a reload.
+	othersMu sync.RWMutex
+	others   map[OTEL]T
+}
+
+type instrumentsEntry[T any] struct {
+	state OTEL
+	v     T
+}
+
+// NewInstruments creates an [Instruments] that uses the build function
+// to create the values for the instance returned by the getter (that
+// can be nil).
+func NewInstruments[T any](getter GetterFn, build func(OTEL) T) *Instruments[T] {
+	return &Instruments[T]{
+		getter: getter,
+		build:  build,
+	}
+}
+
+// Get returns the values for the current [OTEL] instance.
+func (i *Instruments[T]) Get() T {
+	s := i.getter()
+	if e := i.cur.Load(); e != nil && e.state == s {
+		return e.v
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if e := i.cur.Load(); e != nil && e.state == s {
+		return e.v
+	}
+	e := &instrumentsEntry[T]{state: s, v: i.build(s)}
+	i.cur.Store(e)
+	i.othersMu.Lock()
+	i.others = nil
+	i.othersMu.Unlock()
+	return e.v
+}
+
+// For returns the values for the provided [OTEL] instance, like the
+// one selected for an endpoint, or the ones for the current instance
+// when it is nil.
+func (i *Instruments[T]) For(s OTEL) T {
+	if s == nil {
+		return i.Get()
+	}
+	if e := i.cur.Load(); e != nil && e.state == s {
+		return e.v
+	}
+
+	i.othersMu.RLock()
+	v, ok := i.others[s]
+	i.othersMu.RUnlock()
+	if ok {
+		return v
+	}
+
+	i.othersMu.Lock()
+	defer i.othersMu.Unlock()
+	if v, ok := i.others[s]; ok {
+		return v
+	}
+	if i.others == nil {
+		i.others = map[OTEL]T{}
+	}
+	v = i.build(s)
+	i.others[s] = v
+	return v
+}
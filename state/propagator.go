@@ -0,0 +1,27 @@
+package state
+
+import (
+	"fmt"
+
+	"go.opentelemetry.io/contrib/propagators/autoprop"
+	"go.opentelemetry.io/otel/propagation"
+
+	"github.com/krakend/krakend-otel/config"
+)
+
+// NewPropagator creates a composite propagator with the formats in
+// the list (see the names in the config package). It returns nil for
+// an empty list, so the caller can use the default one.
+func NewPropagator(names []string) (propagation.TextMapPropagator, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	if err := config.ValidatePropagators(names); err != nil {
+		return nil, err
+	}
+	p, err := autoprop.TextMapPropagator(names...)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create the propagators: %s", err.Error())
+	}
+	return p, nil
+}
@@ -0,0 +1,39 @@
+package state
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/krakend/krakend-otel/config"
+	"github.com/krakend/krakend-otel/exporter"
+)
+
+func TestOTELState_propagator(t *testing.T) {
+	s, err := NewWithVersion("test", &OTELStateConfig{
+		Propagators: []string{config.PropagatorB3Multi, config.PropagatorBaggage},
+	}, "v1", map[string]exporter.MetricReader{}, map[string]exporter.SpanExporter{})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	defer s.Shutdown(context.Background())
+
+	fields := s.Propagator().Fields()
+	sort.Strings(fields)
+	got := strings.Join(fields, ",")
+	if got != "baggage,x-b3-flags,x-b3-sampled,x-b3-spanid,x-b3-traceid" {
+		t.Errorf("unexpected propagated fields: %s", got)
+	}
+}
+
+func TestNewPropagator(t *testing.T) {
+	p, err := NewPropagator(nil)
+	if err != nil || p != nil {
+		t.Errorf("expected no propagator without names: %v %v", p, err)
+	}
+	if _, err := NewPropagator([]string{"tracecontext", "w3c"}); err == nil {
+		t.Errorf("expected error for an unknown propagator")
+	}
+}
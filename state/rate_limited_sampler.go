@@ -0,0 +1,70 @@
+package state
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// rateLimitedSampler samples up to a number of traces per second,
+// using a token bucket that can hold up to a second of traces, so
+// the volume of exported traces is capped during traffic spikes.
+type rateLimitedSampler struct {
+	perSecond float64
+	max       float64
+	now       func() time.Time
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimitedSampler(perSecond float64, now func() time.Time) *rateLimitedSampler {
+	max := perSecond
+	if max < 1.0 {
+		max = 1.0
+	}
+	return &rateLimitedSampler{
+		perSecond: perSecond,
+		max:       max,
+		now:       now,
+		tokens:    max,
+		last:      now(),
+	}
+}
+
+func (s *rateLimitedSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	res := sdktrace.SamplingResult{
+		Decision:   sdktrace.Drop,
+		Tracestate: trace.SpanContextFromContext(p.ParentContext).TraceState(),
+	}
+	if s.take() {
+		res.Decision = sdktrace.RecordAndSample
+	}
+	return res
+}
+
+func (s *rateLimitedSampler) take() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if elapsed := now.Sub(s.last); elapsed > 0 {
+		s.tokens += elapsed.Seconds() * s.perSecond
+		if s.tokens > s.max {
+			s.tokens = s.max
+		}
+		s.last = now
+	}
+	if s.tokens < 1.0 {
+		return false
+	}
+	s.tokens--
+	return true
+}
+
+func (s *rateLimitedSampler) Description() string {
+	return fmt.Sprintf("RateLimitedSampler{%g}", s.perSecond)
+}
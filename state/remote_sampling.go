@@ -0,0 +1,314 @@
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/semconv/v1.21.0"
+
+	"github.com/krakend/krakend-otel/config"
+)
+
+const (
+	defaultRemoteSamplingInterval = time.Minute
+	remoteSamplingTimeout         = 5 * time.Second
+
+	strategyProbabilistic = "probabilistic"
+	strategyRateLimiting  = "ratelimiting"
+)
+
+// samplingStrategies is the document with the sampling strategies, in
+// the format of the Jaeger sampling strategies file.
+type samplingStrategies struct {
+	ServiceStrategies []serviceStrategy `json:"service_strategies"`
+	DefaultStrategy   *serviceStrategy  `json:"default_strategy"`
+}
+
+type serviceStrategy struct {
+	Service             string              `json:"service"`
+	Type                string              `json:"type"`
+	Param               float64             `json:"param"`
+	OperationStrategies []operationStrategy `json:"operation_strategies"`
+}
+
+type operationStrategy struct {
+	Operation string  `json:"operation"`
+	Type      string  `json:"type"`
+	Param     float64 `json:"param"`
+}
+
+// remoteSampler takes the decision for the spans that start a trace
+// using the strategies loaded from a file or a URL, and the fallback
+// sampler when there is no strategy for them.
+type remoteSampler struct {
+	service  string
+	file     string
+	url      string
+	interval time.Duration
+	client   *http.Client
+	fallback sdktrace.Sampler
+
+	cur      atomic.Pointer[compiledStrategies]
+	stopOnce sync.Once
+	stopCh   chan struct{}
+}
+
+// compiledStrategies has the samplers for the strategies that apply
+// to the service.
+type compiledStrategies struct {
+	def sdktrace.Sampler
+	ops []operationSampler
+	// samplers are kept by strategy, so the rate limiters do not
+	// get their tokens reset when reloading the same strategy.
+	samplers map[string]sdktrace.Sampler
+}
+
+type operationSampler struct {
+	method  string
+	pattern routePattern
+	sampler sdktrace.Sampler
+}
+
+func newRemoteSampler(service string, opts *config.RemoteSamplingOpts) *remoteSampler {
+	interval, err := config.ParseDuration(opts.PollingInterval, defaultRemoteSamplingInterval)
+	if err != nil || interval <= 0 {
+		interval = defaultRemoteSamplingInterval
+	}
+	return &remoteSampler{
+		service:  service,
+		file:     opts.File,
+		url:      opts.URL,
+		interval: interval,
+		client:   &http.Client{Timeout: remoteSamplingTimeout},
+		fallback: sdktrace.AlwaysSample(),
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// start loads the strategies, and keeps polling them until stopped.
+// The errors are reported to the otel error handler, and the last
+// strategies loaded keep being used.
+func (s *remoteSampler) start() {
+	if s == nil {
+		return
+	}
+	if err := s.load(context.Background()); err != nil {
+		otel.Handle(err)
+	}
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stopCh:
+				return
+			case <-ticker.C:
+				if err := s.load(context.Background()); err != nil {
+					otel.Handle(err)
+				}
+			}
+		}
+	}()
+}
+
+func (s *remoteSampler) stop() {
+	if s == nil {
+		return
+	}
+	s.stopOnce.Do(func() { close(s.stopCh) })
+}
+
+func (s *remoteSampler) load(ctx context.Context) error {
+	b, err := s.fetch(ctx)
+	if err != nil {
+		return fmt.Errorf("cannot load the remote sampling strategies: %s", err.Error())
+	}
+	var doc samplingStrategies
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return fmt.Errorf("bad remote sampling strategies: %s", err.Error())
+	}
+	c, err := compileStrategies(&doc, s.service, s.cur.Load())
+	if err != nil {
+		return fmt.Errorf("bad remote sampling strategies: %s", err.Error())
+	}
+	s.cur.Store(c)
+	return nil
+}
+
+func (s *remoteSampler) fetch(ctx context.Context) ([]byte, error) {
+	if s.file != "" {
+		return os.ReadFile(s.file)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func compileStrategies(doc *samplingStrategies, service string,
+	prev *compiledStrategies,
+) (*compiledStrategies, error) {
+	c := &compiledStrategies{samplers: map[string]sdktrace.Sampler{}}
+	// the probabilistic samplers are stateless and can be shared, but
+	// each rate limiting strategy entry (the service default or an
+	// operation) needs its own bucket, that is kept across reloads
+	// while the entry does not change
+	sampler := func(typ string, param float64, operation string) (sdktrace.Sampler, error) {
+		key := typ + ":" + strconv.FormatFloat(param, 'g', -1, 64)
+		if typ == strategyRateLimiting {
+			key += ":" + service + ":" + operation
+		}
+		if s, ok := c.samplers[key]; ok {
+			return s, nil
+		}
+		if prev != nil {
+			if s, ok := prev.samplers[key]; ok {
+				c.samplers[key] = s
+				return s, nil
+			}
+		}
+		var s sdktrace.Sampler
+		switch typ {
+		case strategyProbabilistic:
+			if param < 0 || param > 1 {
+				return nil, fmt.Errorf("bad probabilistic param %g", param)
+			}
+			s = sdktrace.TraceIDRatioBased(param)
+		case strategyRateLimiting:
+			if param <= 0 {
+				return nil, fmt.Errorf("bad ratelimiting param %g", param)
+			}
+			s = newRateLimitedSampler(param, time.Now)
+		default:
+			return nil, fmt.Errorf("unknown strategy type %q", typ)
+		}
+		c.samplers[key] = s
+		return s, nil
+	}
+
+	strategy := doc.DefaultStrategy
+	for i := range doc.ServiceStrategies {
+		if doc.ServiceStrategies[i].Service == service {
+			strategy = &doc.ServiceStrategies[i]
+			break
+		}
+	}
+	if strategy == nil {
+		return c, nil
+	}
+	if strategy.Type != "" {
+		s, err := sampler(strategy.Type, strategy.Param, "")
+		if err != nil {
+			return nil, err
+		}
+		c.def = s
+	}
+
+	ops := strategy.OperationStrategies
+	if len(ops) == 0 && doc.DefaultStrategy != nil {
+		ops = doc.DefaultStrategy.OperationStrategies
+	}
+	for _, op := range ops {
+		typ := op.Type
+		if typ == "" {
+			typ = strategyProbabilistic
+		}
+		s, err := sampler(typ, op.Param, op.Operation)
+		if err != nil {
+			return nil, fmt.Errorf("operation %s: %s", op.Operation, err.Error())
+		}
+		method, route, ok := strings.Cut(op.Operation, " ")
+		if !ok {
+			method, route = "", op.Operation
+		}
+		opSampler := operationSampler{
+			method:  method,
+			pattern: routePattern{segments: strings.Split(config.NormalizeURLPattern(route), "/")},
+			sampler: s,
+		}
+		for _, seg := range opSampler.pattern.segments {
+			if !isRouteParam(seg) {
+				opSampler.pattern.static++
+			}
+		}
+		c.ops = append(c.ops, opSampler)
+	}
+	return c, nil
+}
+
+// forOperation returns the sampler for the operation that
+// matches the route (or the path) of the span.
+func (c *compiledStrategies) forOperation(attrs []attribute.KeyValue) sdktrace.Sampler {
+	if len(c.ops) == 0 {
+		return nil
+	}
+	var method, route, path string
+	for _, kv := range attrs {
+		switch kv.Key {
+		case semconv.HTTPRequestMethodKey:
+			method = kv.Value.AsString()
+		case semconv.HTTPRouteKey:
+			route = kv.Value.AsString()
+		case semconv.URLPathKey:
+			path = kv.Value.AsString()
+		}
+	}
+	if route != "" {
+		path = route
+	}
+	if path == "" {
+		return nil
+	}
+
+	segments := strings.Split(path, "/")
+	best := -1
+	for i, op := range c.ops {
+		if (op.method != "" && op.method != method) || !op.pattern.match(segments) {
+			continue
+		}
+		if best < 0 || op.pattern.static > c.ops[best].pattern.static {
+			best = i
+		}
+	}
+	if best < 0 {
+		return nil
+	}
+	return c.ops[best].sampler
+}
+
+func (s *remoteSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	if c := s.cur.Load(); c != nil {
+		if opSampler := c.forOperation(p.Attributes); opSampler != nil {
+			return opSampler.ShouldSample(p)
+		}
+		if c.def != nil {
+			return c.def.ShouldSample(p)
+		}
+	}
+	return s.fallback.ShouldSample(p)
+}
+
+func (s *remoteSampler) Description() string {
+	return "RemoteSampler{" + s.fallback.Description() + "}"
+}
@@ -0,0 +1,155 @@
+package state
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/krakend/krakend-otel/config"
+)
+
+const testStrategies = `{
+	"service_strategies": [
+		{
+			"service": "krakend",
+			"type": "probabilistic",
+			"param": 1,
+			"operation_strategies": [
+				{"operation": "/__health", "type": "probabilistic", "param": 0},
+				{"operation": "GET /users/:id", "type": "probabilistic", "param": 0},
+				{"operation": "/orders/{{.Id}}", "type": "ratelimiting", "param": 2},
+				{"operation": "/invoices/:id", "type": "ratelimiting", "param": 2}
+			]
+		}
+	],
+	"default_strategy": {"type": "probabilistic", "param": 0}
+}`
+
+func serverSpanParams(method, path string) sdktrace.SamplingParameters {
+	return sdktrace.SamplingParameters{
+		ParentContext: context.Background(),
+		TraceID:       trace.TraceID{8: 0x01},
+		Attributes: []attribute.KeyValue{
+			semconv.HTTPRequestMethodKey.String(method),
+			semconv.URLPath(path),
+		},
+	}
+}
+
+func TestRemoteSampler_url(t *testing.T) {
+	var doc atomic.Value
+	doc.Store(testStrategies)
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		rw.Write([]byte(doc.Load().(string)))
+	}))
+	defer srv.Close()
+
+	s := newRemoteSampler("krakend", &config.RemoteSamplingOpts{URL: srv.URL})
+	s.fallback = sdktrace.NeverSample()
+	if err := s.load(context.Background()); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+
+	for _, tc := range []struct {
+		name     string
+		method   string
+		path     string
+		expected []bool
+	}{
+		{"health", "GET", "/__health", []bool{false}},
+		{"operation_method", "GET", "/users/42", []bool{false}},
+		{"other_method", "DELETE", "/users/42", []bool{true}},
+		{"service_default", "GET", "/products", []bool{true}},
+		{"rate_limited", "GET", "/orders/1", []bool{true, true, false}},
+		{"own_rate_limit", "GET", "/invoices/1", []bool{true, true, false}},
+	} {
+		for i, expected := range tc.expected {
+			res := s.ShouldSample(serverSpanParams(tc.method, tc.path))
+			if (res.Decision == sdktrace.RecordAndSample) != expected {
+				t.Errorf("%s #%d: expected sampled %v", tc.name, i, expected)
+			}
+		}
+	}
+
+	// the rate limiters are kept when the strategy does not change
+	if err := s.load(context.Background()); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	if s.ShouldSample(serverSpanParams("GET", "/orders/2")).Decision == sdktrace.RecordAndSample {
+		t.Errorf("the rate limiter has been reset by the reload")
+	}
+
+	// a bad document keeps the previous strategies
+	doc.Store(`{"default_strategy": {"type": "adaptive"}}`)
+	if err := s.load(context.Background()); err == nil {
+		t.Errorf("expected an error for an unknown strategy type")
+	}
+	if s.ShouldSample(serverSpanParams("GET", "/products")).Decision != sdktrace.RecordAndSample {
+		t.Errorf("expected the previous strategies to be kept")
+	}
+
+	// other service uses the default strategy
+	doc.Store(testStrategies)
+	s.service = "other"
+	if err := s.load(context.Background()); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	if s.ShouldSample(serverSpanParams("GET", "/products")).Decision == sdktrace.RecordAndSample {
+		t.Errorf("expected the default strategy to be used")
+	}
+}
+
+func TestRemoteSampler_file(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "strategies.json")
+	if err := os.WriteFile(path, []byte(`{"service_strategies": []}`), 0o600); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	s := newRemoteSampler("krakend", &config.RemoteSamplingOpts{File: path})
+	s.fallback = sdktrace.NeverSample()
+	if err := s.load(context.Background()); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	if s.ShouldSample(serverSpanParams("GET", "/products")).Decision == sdktrace.RecordAndSample {
+		t.Errorf("expected the fallback sampler to be used without strategies")
+	}
+
+	if err := os.WriteFile(path, []byte(testStrategies), 0o600); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	if err := s.load(context.Background()); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	if s.ShouldSample(serverSpanParams("GET", "/products")).Decision != sdktrace.RecordAndSample {
+		t.Errorf("expected the service strategy to be used")
+	}
+}
+
+func TestRemoteSamplingOpts_validate(t *testing.T) {
+	for _, opts := range []*config.RemoteSamplingOpts{
+		{},
+		{File: "strategies.json", URL: "http://localhost:5778/sampling"},
+		{URL: "localhost:5778"},
+		{File: "strategies.json", PollingInterval: "often"},
+	} {
+		cfg := &config.ConfigData{RemoteSampling: opts}
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("expected error for %+v", opts)
+		}
+	}
+}
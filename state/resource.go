@@ -0,0 +1,96 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkresource "go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/semconv/v1.21.0"
+
+	"github.com/krakend/krakend-otel/config"
+)
+
+// kubernetesEnvAttrs maps the environment variables usually set with
+// the Kubernetes downward API to their resource attributes.
+var kubernetesEnvAttrs = map[string]attribute.Key{
+	"K8S_POD_NAME":        semconv.K8SPodNameKey,
+	"K8S_POD_UID":         semconv.K8SPodUIDKey,
+	"K8S_NAMESPACE_NAME":  semconv.K8SNamespaceNameKey,
+	"K8S_NODE_NAME":       semconv.K8SNodeNameKey,
+	"K8S_CONTAINER_NAME":  semconv.K8SContainerNameKey,
+	"K8S_DEPLOYMENT_NAME": semconv.K8SDeploymentNameKey,
+}
+
+// newResource creates the resource for the service with the detected,
+// environment and static attributes from the options.
+//
+// Each detector might use a different semantic conventions schema than
+// the one we use, and merging resources with different schemas fails:
+// as we control the service attributes, only our schema is kept.
+func newResource(ctx context.Context, base []attribute.KeyValue,
+	opts *config.ResourceOpts,
+) (*sdkresource.Resource, error) {
+	res := sdkresource.NewWithAttributes(semconv.SchemaURL, base...)
+	if opts == nil {
+		return res, nil
+	}
+
+	var attrs []attribute.KeyValue
+	for _, d := range opts.Detectors {
+		var opt sdkresource.Option
+		switch d {
+		case config.DetectorHost:
+			opt = sdkresource.WithHost()
+		case config.DetectorOS:
+			opt = sdkresource.WithOS()
+		case config.DetectorProcess:
+			opt = sdkresource.WithProcess()
+		case config.DetectorContainer:
+			opt = sdkresource.WithContainer()
+		case config.DetectorKubernetes:
+			attrs = append(attrs, kubernetesAttrs()...)
+			continue
+		default:
+			return nil, errors.New("unknown resource detector " + d)
+		}
+		r, err := sdkresource.New(ctx, opt)
+		if err != nil && !errors.Is(err, sdkresource.ErrPartialResource) {
+			return nil, err
+		}
+		if r != nil {
+			attrs = append(attrs, r.Attributes()...)
+		}
+	}
+
+	if opts.FromEnv {
+		r, err := sdkresource.New(ctx, sdkresource.WithFromEnv())
+		if err != nil && !errors.Is(err, sdkresource.ErrPartialResource) {
+			return nil, err
+		}
+		if r != nil {
+			attrs = append(attrs, r.Attributes()...)
+		}
+	}
+
+	for _, kv := range opts.ResourceAttributes {
+		if kv.Key != "" {
+			attrs = append(attrs, attribute.String(kv.Key, kv.Value))
+		}
+	}
+
+	// for repeated keys, the last value is the one used, and the
+	// resource attributes take precedence over the detected ones.
+	return sdkresource.Merge(sdkresource.NewSchemaless(attrs...), res)
+}
+
+func kubernetesAttrs() []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	for env, key := range kubernetesEnvAttrs {
+		if v := os.Getenv(env); v != "" {
+			attrs = append(attrs, key.String(v))
+		}
+	}
+	return attrs
+}
@@ -0,0 +1,79 @@
+package state
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/semconv/v1.21.0"
+
+	"github.com/krakend/krakend-otel/config"
+)
+
+func TestNewResource(t *testing.T) {
+	t.Setenv("OTEL_RESOURCE_ATTRIBUTES", "team=platform,region=env-region,service.name=from-env")
+	t.Setenv("K8S_POD_NAME", "krakend-abc")
+	t.Setenv("K8S_NAMESPACE_NAME", "gateways")
+
+	base := []attribute.KeyValue{
+		semconv.ServiceName("krakend"),
+		semconv.ServiceVersion("v1.0.0"),
+	}
+	res, err := newResource(context.Background(), base, &config.ResourceOpts{
+		Detectors: []string{"host", "os", "process", "kubernetes"},
+		ResourceAttributes: config.Attributes{
+			{Key: "region", Value: "eu-west-1"},
+			{Key: "service.version", Value: "ignored"},
+		},
+		FromEnv: true,
+	})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	if res.SchemaURL() != semconv.SchemaURL {
+		t.Errorf("expected schema %s, got %s", semconv.SchemaURL, res.SchemaURL())
+	}
+
+	got := map[attribute.Key]string{}
+	for _, kv := range res.Attributes() {
+		got[kv.Key] = kv.Value.Emit()
+	}
+	expected := map[attribute.Key]string{
+		semconv.ServiceNameKey:      "krakend",
+		semconv.ServiceVersionKey:   "v1.0.0",
+		"team":                      "platform",
+		"region":                    "eu-west-1",
+		semconv.K8SPodNameKey:       "krakend-abc",
+		semconv.K8SNamespaceNameKey: "gateways",
+	}
+	for k, v := range expected {
+		if got[k] != v {
+			t.Errorf("attribute %s: expected %q, got %q", k, v, got[k])
+		}
+	}
+	for _, k := range []attribute.Key{semconv.HostNameKey, semconv.OSTypeKey, semconv.ProcessPIDKey} {
+		if _, ok := got[k]; !ok {
+			t.Errorf("missing detected attribute %s", k)
+		}
+	}
+}
+
+func TestNewResource_defaults(t *testing.T) {
+	t.Setenv("OTEL_RESOURCE_ATTRIBUTES", "team=platform")
+	res, err := newResource(context.Background(), []attribute.KeyValue{semconv.ServiceName("krakend")}, nil)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	if n := len(res.Attributes()); n != 1 {
+		t.Errorf("expected only the service name, got %d attributes: %v", n, res.Attributes())
+	}
+}
+
+func TestResourceOpts_validate(t *testing.T) {
+	cfg := &config.ConfigData{Resource: &config.ResourceOpts{Detectors: []string{"aws"}}}
+	if err := cfg.Validate(); err == nil {
+		t.Errorf("expected error for an unknown detector")
+	}
+}
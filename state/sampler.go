@@ -0,0 +1,285 @@
+package state
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/semconv/v1.21.0"
+
+	"github.com/krakend/krakend-otel/config"
+)
+
+const (
+	endpointRouteKey  = attribute.Key("krakend.endpoint.route")
+	endpointMethodKey = attribute.Key("krakend.endpoint.method")
+)
+
+// SampleRates has the sample rates configured for the endpoints and
+// backends. They are created with the [StateConfig], set when the
+// pipeline is built, and used by the samplers of all its states, so
+// they must be kept when the states are replaced by a reload.
+type SampleRates struct {
+	mu        sync.RWMutex
+	endpoints map[string]float64
+	patterns  []routePattern
+	backends  map[string]float64
+}
+
+// routePattern is used to find the endpoint for the url path of
+// a span that starts before the route has been matched (like the
+// one for the server side of the request).
+type routePattern struct {
+	method   string
+	segments []string
+	static   int
+	rate     float64
+}
+
+// NewSampleRates creates an empty set of sample rates.
+func NewSampleRates() *SampleRates {
+	return &SampleRates{
+		endpoints: map[string]float64{},
+		backends:  map[string]float64{},
+	}
+}
+
+// SetEndpointSampleRate sets the sample rate for the traces of the
+// requests to an endpoint, identified by its method and its route
+// (as normalized with [config.NormalizeURLPattern]).
+func (r *SampleRates) SetEndpointSampleRate(method, route string, rate float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := method + " " + route
+	if _, ok := r.endpoints[key]; ok {
+		for i := range r.patterns {
+			p := &r.patterns[i]
+			if p.method == method && strings.Join(p.segments, "/") == route {
+				p.rate = rate
+			}
+		}
+	} else {
+		p := routePattern{
+			method:   method,
+			segments: strings.Split(route, "/"),
+			rate:     rate,
+		}
+		for _, s := range p.segments {
+			if !isRouteParam(s) {
+				p.static++
+			}
+		}
+		r.patterns = append(r.patterns, p)
+	}
+	r.endpoints[key] = rate
+}
+
+// SetBackendSampleRate sets the sample rate for the spans of a backend,
+// identified by the method and route of its endpoint, and its own method
+// and url pattern.
+func (r *SampleRates) SetBackendSampleRate(endpointMethod, endpointRoute, method, route string, rate float64) {
+	r.mu.Lock()
+	r.backends[endpointMethod+" "+endpointRoute+" "+method+" "+route] = rate
+	r.mu.Unlock()
+}
+
+// SampleRatesConfig is implemented by the [Config] instances that
+// keep the sample rates of the endpoints and backends.
+type SampleRatesConfig interface {
+	SampleRates() *SampleRates
+}
+
+// SampleRatesFromConfig returns the [SampleRates] of the config, or
+// nil if the config does not implement [SampleRatesConfig].
+func SampleRatesFromConfig(c Config) *SampleRates {
+	if sc, ok := c.(SampleRatesConfig); ok {
+		return sc.SampleRates()
+	}
+	return nil
+}
+
+func isRouteParam(segment string) bool {
+	return strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*")
+}
+
+// endpointRate returns the rate for the endpoint of a span, using the
+// http.route attribute, or matching the url.path one.
+func (r *SampleRates) endpointRate(attrs []attribute.KeyValue) (float64, bool) {
+	if r == nil {
+		return 0, false
+	}
+	var method, route, path string
+	for _, kv := range attrs {
+		switch kv.Key {
+		case semconv.HTTPRequestMethodKey:
+			method = kv.Value.AsString()
+		case semconv.HTTPRouteKey:
+			route = kv.Value.AsString()
+		case semconv.URLPathKey:
+			path = kv.Value.AsString()
+		}
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if route != "" {
+		rate, ok := r.endpoints[method+" "+route]
+		return rate, ok
+	}
+	if path == "" || len(r.patterns) == 0 {
+		return 0, false
+	}
+
+	segments := strings.Split(path, "/")
+	best := -1
+	for i, p := range r.patterns {
+		if p.method != method || !p.match(segments) {
+			continue
+		}
+		if best < 0 || p.static > r.patterns[best].static {
+			best = i
+		}
+	}
+	if best < 0 {
+		return 0, false
+	}
+	return r.patterns[best].rate, true
+}
+
+func (p *routePattern) match(segments []string) bool {
+	for i, s := range p.segments {
+		if strings.HasPrefix(s, "*") {
+			return true
+		}
+		if i >= len(segments) {
+			return false
+		}
+		if s != segments[i] && !strings.HasPrefix(s, ":") {
+			return false
+		}
+	}
+	return len(segments) == len(p.segments)
+}
+
+// backendRate returns the rate for the backend of a span.
+func (r *SampleRates) backendRate(attrs []attribute.KeyValue) (float64, bool) {
+	if r == nil {
+		return 0, false
+	}
+	var endpointMethod, endpointRoute, method, route string
+	for _, kv := range attrs {
+		switch kv.Key {
+		case endpointMethodKey:
+			endpointMethod = kv.Value.AsString()
+		case endpointRouteKey:
+			endpointRoute = kv.Value.AsString()
+		case semconv.HTTPRequestMethodKey:
+			method = kv.Value.AsString()
+		case semconv.HTTPRouteKey:
+			route = kv.Value.AsString()
+		}
+	}
+	if endpointRoute == "" {
+		return 0, false
+	}
+	r.mu.RLock()
+	rate, ok := r.backends[endpointMethod+" "+endpointRoute+" "+method+" "+route]
+	r.mu.RUnlock()
+	return rate, ok
+}
+
+// routeSampler takes the decision for the spans that start a trace,
+// using the sample rate of their endpoint when it has one.
+type routeSampler struct {
+	rates    *SampleRates
+	fallback sdktrace.Sampler
+}
+
+func (s *routeSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	if rate, ok := s.rates.endpointRate(p.Attributes); ok {
+		return sdktrace.TraceIDRatioBased(rate).ShouldSample(p)
+	}
+	return s.fallback.ShouldSample(p)
+}
+
+func (s *routeSampler) Description() string {
+	return "RouteSampler{" + s.fallback.Description() + "}"
+}
+
+// backendSampler takes the decision for the spans that have a local
+// parent that is sampled, using the sample rate of their backend when
+// it has one (so it can only drop spans).
+type backendSampler struct {
+	rates *SampleRates
+}
+
+func (s *backendSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	if rate, ok := s.rates.backendRate(p.Attributes); ok {
+		return sdktrace.TraceIDRatioBased(rate).ShouldSample(p)
+	}
+	return sdktrace.AlwaysSample().ShouldSample(p)
+}
+
+func (*backendSampler) Description() string {
+	return "BackendSampler"
+}
+
+// newSampler creates the sampler for a state: parent based, with the
+// sample rate of the endpoints and backends (when rates is not nil)
+// that override the default
+// one, that can be a ratio or a rate limit (or the remote strategies,
+// when available), and that can be forced for a request.
+//
+// As before having the overrides, with the default rate of 1 (or 0, that
+// means unset) all the traces are sampled even with a remote parent that
+// is not sampled.
+func newSampler(rate float64, opts *config.SamplerOpts, rates *SampleRates,
+	remote *remoteSampler,
+) sdktrace.Sampler {
+	fallback := sdktrace.AlwaysSample()
+	ratio := opts == nil || opts.Type == "" || opts.Type == config.SamplerTypeRatio
+	switch {
+	case !ratio && opts.Type == config.SamplerTypeAlwaysOff:
+		fallback = sdktrace.NeverSample()
+	case !ratio:
+		fallback = newRateLimitedSampler(opts.TracesPerSecond, time.Now)
+	case rate > 0.0 && rate < 1.0:
+		fallback = sdktrace.TraceIDRatioBased(rate)
+	}
+	if remote != nil {
+		remote.fallback = fallback
+		fallback = remote
+	}
+	root := &routeSampler{rates: rates, fallback: fallback}
+	parentOpts := []sdktrace.ParentBasedSamplerOption{
+		sdktrace.WithLocalParentSampled(&backendSampler{rates: rates}),
+	}
+	if !opts.IsParentBased() {
+		parentOpts = append(parentOpts, sdktrace.WithRemoteParentSampled(root),
+			sdktrace.WithRemoteParentNotSampled(root))
+	} else if ratio && (rate <= 0.0 || rate >= 1.0) {
+		parentOpts = append(parentOpts, sdktrace.WithRemoteParentNotSampled(root))
+	}
+	return &forceSampler{next: sdktrace.ParentBased(root, parentOpts...)}
+}
+
+// forceSampler records and samples the spans started with a context
+// created with [ContextWithForceSampling].
+type forceSampler struct {
+	next sdktrace.Sampler
+}
+
+func (s *forceSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	if p.ParentContext != nil {
+		if _, forced := ForceSampling(p.ParentContext); forced {
+			return sdktrace.AlwaysSample().ShouldSample(p)
+		}
+	}
+	return s.next.ShouldSample(p)
+}
+
+func (s *forceSampler) Description() string {
+	return "ForceSampler{" + s.next.Description() + "}"
+}
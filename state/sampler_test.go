@@ -0,0 +1,182 @@
+package state
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/krakend/krakend-otel/config"
+	"github.com/krakend/krakend-otel/exporter"
+)
+
+func TestSampler_routes(t *testing.T) {
+	rates := NewSampleRates()
+	rates.SetEndpointSampleRate("GET", "/__health", 0)
+	rates.SetEndpointSampleRate("GET", "/users/:id", 0)
+	rates.SetEndpointSampleRate("GET", "/users/me", 1)
+	rates.SetEndpointSampleRate("GET", "/files/*path", 0)
+	rates.SetBackendSampleRate("GET", "/orders", "GET", "/v1/orders", 0)
+
+	s, err := NewWithVersion("test", &OTELStateConfig{
+		TraceProviders:  []string{"test"},
+		TraceSampleRate: 1.0,
+		SampleRates:     rates,
+	}, "v0.0.0", nil, map[string]exporter.SpanExporter{"test": newTestSpanExporter(nil)})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	defer s.Shutdown(context.Background())
+	tracer := s.Tracer()
+
+	for _, tc := range []struct {
+		name    string
+		attrs   []attribute.KeyValue
+		sampled bool
+	}{
+		{"path", []attribute.KeyValue{semconv.HTTPRequestMethodKey.String("GET"), semconv.URLPath("/__health")}, false},
+		{"other_method", []attribute.KeyValue{semconv.HTTPRequestMethodKey.String("POST"), semconv.URLPath("/__health")}, true},
+		{"param", []attribute.KeyValue{semconv.HTTPRequestMethodKey.String("GET"), semconv.URLPath("/users/42")}, false},
+		{"most_specific", []attribute.KeyValue{semconv.HTTPRequestMethodKey.String("GET"), semconv.URLPath("/users/me")}, true},
+		{"wildcard", []attribute.KeyValue{semconv.HTTPRequestMethodKey.String("GET"), semconv.URLPath("/files/a/b")}, false},
+		{"no_match", []attribute.KeyValue{semconv.HTTPRequestMethodKey.String("GET"), semconv.URLPath("/users/42/orders")}, true},
+		{"route", []attribute.KeyValue{semconv.HTTPRequestMethodKey.String("GET"), semconv.HTTPRoute("/users/:id")}, false},
+	} {
+		_, span := tracer.Start(context.Background(), tc.name, trace.WithAttributes(tc.attrs...))
+		if span.SpanContext().IsSampled() != tc.sampled {
+			t.Errorf("%s: expected sampled %v", tc.name, tc.sampled)
+		}
+		span.End()
+	}
+
+	ctx, parent := tracer.Start(context.Background(), "parent")
+	defer parent.End()
+	backendAttrs := []attribute.KeyValue{
+		semconv.HTTPRequestMethodKey.String("GET"),
+		semconv.HTTPRoute("/v1/orders"),
+		attribute.String("krakend.endpoint.route", "/orders"),
+		attribute.String("krakend.endpoint.method", "GET"),
+	}
+	_, span := tracer.Start(ctx, "backend", trace.WithAttributes(backendAttrs...))
+	if span.SpanContext().IsSampled() {
+		t.Errorf("expected the backend span to be dropped")
+	}
+	span.End()
+	_, span = tracer.Start(ctx, "child")
+	if !span.SpanContext().IsSampled() {
+		t.Errorf("expected the child span to follow its parent")
+	}
+	span.End()
+
+	// with the default rate, a remote parent that is not
+	// sampled does not prevent sampling
+	remote := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{1},
+		SpanID:  trace.SpanID{1},
+		Remote:  true,
+	})
+	_, span = tracer.Start(trace.ContextWithRemoteSpanContext(context.Background(), remote), "remote")
+	if !span.SpanContext().IsSampled() {
+		t.Errorf("expected the span with a remote parent to be sampled")
+	}
+	span.End()
+}
+
+func TestRateLimitedSampler(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	s := newRateLimitedSampler(10, func() time.Time { return now })
+
+	// a spike of 20x the allowed rate during 5 seconds, with
+	// a request every 5ms
+	sampled := 0
+	for i := 0; i < 1000; i++ {
+		now = now.Add(5 * time.Millisecond)
+		if s.ShouldSample(sdktrace.SamplingParameters{}).Decision == sdktrace.RecordAndSample {
+			sampled++
+		}
+	}
+	// the initial burst of 10, and 10 per second after it
+	if sampled < 55 || sampled > 61 {
+		t.Errorf("expected around 60 sampled traces, got %d", sampled)
+	}
+
+	// after some idle time, the bucket does not hold
+	// more than a second of traces
+	now = now.Add(time.Minute)
+	sampled = 0
+	for i := 0; i < 100; i++ {
+		if s.ShouldSample(sdktrace.SamplingParameters{}).Decision == sdktrace.RecordAndSample {
+			sampled++
+		}
+	}
+	if sampled != 10 {
+		t.Errorf("expected a burst of 10 sampled traces, got %d", sampled)
+	}
+}
+
+func TestNewSampler_parentBased(t *testing.T) {
+	remoteCtx := func(sampled bool) context.Context {
+		cfg := trace.SpanContextConfig{
+			TraceID: trace.TraceID{2},
+			SpanID:  trace.SpanID{2},
+			Remote:  true,
+		}
+		if sampled {
+			cfg.TraceFlags = trace.FlagsSampled
+		}
+		return trace.ContextWithRemoteSpanContext(context.Background(), trace.NewSpanContext(cfg))
+	}
+	notParentBased := false
+	for _, tc := range []struct {
+		name     string
+		opts     *config.SamplerOpts
+		sampled  bool
+		expected bool
+	}{
+		{"rate_limited_remote_sampled", &config.SamplerOpts{Type: config.SamplerTypeRateLimited, TracesPerSecond: 1}, true, true},
+		{"rate_limited_remote_not_sampled", &config.SamplerOpts{Type: config.SamplerTypeRateLimited, TracesPerSecond: 1}, false, false},
+		{"not_parent_based", &config.SamplerOpts{Type: config.SamplerTypeRateLimited, TracesPerSecond: 1, ParentBased: &notParentBased}, false, true},
+		{"always_off_remote_sampled", &config.SamplerOpts{Type: config.SamplerTypeAlwaysOff}, true, true},
+		{"always_off_remote_not_sampled", &config.SamplerOpts{Type: config.SamplerTypeAlwaysOff}, false, false},
+		{"always_off_not_parent_based", &config.SamplerOpts{Type: config.SamplerTypeAlwaysOff, ParentBased: &notParentBased}, true, false},
+	} {
+		s := newSampler(1, tc.opts, nil, nil)
+		res := s.ShouldSample(sdktrace.SamplingParameters{
+			ParentContext: remoteCtx(tc.sampled),
+			TraceID:       trace.TraceID{2},
+		})
+		if (res.Decision == sdktrace.RecordAndSample) != tc.expected {
+			t.Errorf("%s: unexpected decision %v", tc.name, res.Decision)
+		}
+	}
+}
+
+func TestSamplerOpts_validate(t *testing.T) {
+	for _, opts := range []*config.SamplerOpts{
+		{Type: "always"},
+		{Type: config.SamplerTypeRateLimited},
+	} {
+		cfg := &config.ConfigData{Sampler: opts}
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("expected error for %+v", opts)
+		}
+	}
+}
+
+func TestNewSampler_forced(t *testing.T) {
+	s := newSampler(0.000001, nil, nil, nil)
+	ctx := context.Background()
+	params := sdktrace.SamplingParameters{ParentContext: ctx, TraceID: trace.TraceID{8: 0xff, 9: 0xff}}
+	if s.ShouldSample(params).Decision == sdktrace.RecordAndSample {
+		t.Errorf("expected the trace to be dropped")
+	}
+	params.ParentContext = ContextWithForceSampling(ctx, "X-Debug")
+	if s.ShouldSample(params).Decision != sdktrace.RecordAndSample {
+		t.Errorf("expected the forced trace to be sampled")
+	}
+}
@@ -0,0 +1,220 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+
+	luraconfig "github.com/luraproject/lura/v2/config"
+	lcore "github.com/luraproject/lura/v2/core"
+
+	"github.com/krakend/krakend-otel/config"
+	"github.com/krakend/krakend-otel/exporter"
+)
+
+// selectedStates creates and caches the states for the endpoints and
+// backends that select their own exporters (see [config.ExporterSelection]).
+//
+// There is a single state for each distinct combination of exporters,
+// shared by all the endpoints and backends that select it, and the ones
+// that select the default exporters use the global state. The states
+// use the sampler and the tail sampler of the global state, so the
+// remote strategies are only loaded once, and the spans of a trace
+// reported to different exporters are kept or dropped together.
+type selectedStates struct {
+	serviceName string
+	version     string
+	env         string
+	stateCfg    OTELStateConfig
+	me          map[string]exporter.MetricReader
+	te          map[string]exporter.SpanExporter
+
+	defaultMetrics []string
+	defaultTraces  []string
+
+	// byConfig caches the state for each lura config (the key is the
+	// pointer to the endpoint or backend config), where a nil state
+	// means using the global one.
+	byConfig sync.Map
+
+	mu     sync.Mutex
+	states map[string]*OTELState
+}
+
+func newSelectedStates(cfgData *config.ConfigData, me map[string]exporter.MetricReader,
+	te map[string]exporter.SpanExporter, rates *SampleRates,
+) *selectedStates {
+	version := cfgData.ServiceVersion
+	if version == "" {
+		version = lcore.KrakendVersion
+	}
+	ss := &selectedStates{
+		serviceName: cfgData.ServiceName,
+		version:     version,
+		env:         cfgData.DeployEnv,
+		stateCfg: OTELStateConfig{
+			MetricReportingPeriod: *cfgData.MetricReportingPeriod,
+			TraceSampleRate:       *cfgData.TraceSampleRate,
+			Sampler:               cfgData.Sampler,
+			RemoteSampling:        cfgData.RemoteSampling,
+			Propagators:           cfgData.Propagators,
+			BaggageAttributes:     cfgData.GlobalBaggageAttributes(),
+			Resource:              cfgData.Resource,
+			TailSampling:          cfgData.TailSampling,
+			SampleRates:           rates,
+		},
+		me:     me,
+		te:     te,
+		states: map[string]*OTELState{},
+	}
+	for k, v := range me {
+		if v.MetricDefaultReporting() {
+			ss.defaultMetrics = append(ss.defaultMetrics, k)
+		}
+	}
+	for k, v := range te {
+		if v.TraceDefaultReporting() {
+			ss.defaultTraces = append(ss.defaultTraces, k)
+		}
+	}
+	sort.Strings(ss.defaultMetrics)
+	sort.Strings(ss.defaultTraces)
+	return ss
+}
+
+// forConfig returns the state for the endpoint or backend config
+// identified by key, with its extra config.
+func (ss *selectedStates) forConfig(key any, extraCfg luraconfig.ExtraConfig) OTEL {
+	v, ok := ss.byConfig.Load(key)
+	if !ok {
+		v, _ = ss.byConfig.LoadOrStore(key, ss.resolve(extraCfg))
+	}
+	if s := v.(*OTELState); s != nil {
+		return s
+	}
+	return GlobalState()
+}
+
+// resolve returns the state for the exporters selected in the extra
+// config, or nil when the default exporters must be used.
+func (ss *selectedStates) resolve(extraCfg luraconfig.ExtraConfig) *OTELState {
+	sel, err := config.LuraExporterSelectionExtraCfg(extraCfg)
+	if err != nil {
+		if !errors.Is(err, config.ErrNoConfig) {
+			otel.Handle(fmt.Errorf("bad exporters selection: %s", err.Error()))
+		}
+		return nil
+	}
+	if sel.Empty() {
+		return nil
+	}
+
+	metrics := ss.defaultMetrics
+	if sel.MetricExporters != nil {
+		metrics = uniqueSorted(sel.MetricExporters)
+	}
+	traces := ss.defaultTraces
+	if sel.TraceExporters != nil {
+		traces = uniqueSorted(sel.TraceExporters)
+	}
+	mKey := strings.Join(metrics, ",")
+	tKey := strings.Join(traces, ",")
+	if mKey == strings.Join(ss.defaultMetrics, ",") && tKey == strings.Join(ss.defaultTraces, ",") {
+		return nil
+	}
+	key := "metrics:" + mKey + "|traces:" + tKey
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if s, ok := ss.states[key]; ok {
+		return s
+	}
+	cfg := ss.stateCfg
+	cfg.MetricProviders = metrics
+	cfg.TraceProviders = traces
+	// the sampling is shared with the global state
+	otelStateMutex.RLock()
+	cfg.base = otelState
+	otelStateMutex.RUnlock()
+	s, err := NewWithLogs(ss.serviceName, &cfg, ss.version, ss.env, ss.me, ss.te, nil)
+	if err != nil {
+		// we fall back to the global state, and do not try it again
+		otel.Handle(fmt.Errorf("cannot create the state for the selected exporters (%s): %s",
+			key, err.Error()))
+		s = nil
+	}
+	ss.states[key] = s
+	return s
+}
+
+// validate checks that the exporters selected in the extra config exist.
+func (ss *selectedStates) validate(extraCfg luraconfig.ExtraConfig) error {
+	sel, err := config.LuraExporterSelectionExtraCfg(extraCfg)
+	if err != nil {
+		if errors.Is(err, config.ErrNoConfig) {
+			return nil
+		}
+		return fmt.Errorf("bad exporters selection: %s", err.Error())
+	}
+	for _, n := range sel.MetricExporters {
+		if _, ok := ss.me[n]; !ok {
+			return fmt.Errorf("unknown metric exporter %q in the exporters selection", n)
+		}
+	}
+	for _, n := range sel.TraceExporters {
+		if _, ok := ss.te[n]; !ok {
+			return fmt.Errorf("unknown trace exporter %q in the exporters selection", n)
+		}
+	}
+	return nil
+}
+
+// ExporterSelectionConfig is implemented by the [Config] instances
+// that can check the exporters selected by endpoints and backends.
+type ExporterSelectionConfig interface {
+	ValidateExporterSelection(extraCfg luraconfig.ExtraConfig) error
+}
+
+// ValidateExporterSelection checks the exporters selected in the extra
+// config of an endpoint or backend, when the config implements
+// [ExporterSelectionConfig].
+func ValidateExporterSelection(c Config, extraCfg luraconfig.ExtraConfig) error {
+	if vc, ok := c.(ExporterSelectionConfig); ok {
+		return vc.ValidateExporterSelection(extraCfg)
+	}
+	return nil
+}
+
+func (ss *selectedStates) forceFlush(ctx context.Context) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	for _, s := range ss.states {
+		s.ForceFlush(ctx)
+	}
+}
+
+func (ss *selectedStates) shutdown(ctx context.Context) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	for _, s := range ss.states {
+		s.Shutdown(ctx)
+	}
+}
+
+func uniqueSorted(names []string) []string {
+	res := make([]string, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, n := range names {
+		if !seen[n] {
+			seen[n] = true
+			res = append(res, n)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
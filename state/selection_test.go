@@ -0,0 +1,169 @@
+package state
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+
+	luraconfig "github.com/luraproject/lura/v2/config"
+
+	"github.com/krakend/krakend-otel/config"
+	"github.com/krakend/krakend-otel/exporter"
+)
+
+type selectableSpanExporter struct {
+	*testSpanExporter
+	defaultReporting bool
+}
+
+func (e *selectableSpanExporter) TraceDefaultReporting() bool {
+	return e.defaultReporting
+}
+
+func endpointWithSelection(path string, sel map[string]interface{}) *luraconfig.EndpointConfig {
+	cfg := &luraconfig.EndpointConfig{Endpoint: path}
+	if sel != nil {
+		cfg.ExtraConfig = luraconfig.ExtraConfig{config.Namespace: sel}
+	}
+	return cfg
+}
+
+func TestStateConfig_exporterSelection(t *testing.T) {
+	sync := &config.BatchOpts{Synchronous: true}
+	main := &selectableSpanExporter{newTestSpanExporter(sync), true}
+	compliance := &selectableSpanExporter{newTestSpanExporter(sync), false}
+	te := map[string]exporter.SpanExporter{
+		"main":       main,
+		"compliance": compliance,
+	}
+
+	global, err := NewWithVersion("test", &OTELStateConfig{
+		TraceProviders:  []string{"main"},
+		TraceSampleRate: 1.0,
+	}, "v0.0.0", nil, te)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	SetGlobalState(global)
+	defer SetGlobalState(nil)
+
+	stateCfg := NewConfigWithExporters(&config.ConfigData{ServiceName: "test"}, nil, te)
+	defer stateCfg.Shutdown(context.Background())
+
+	payments := endpointWithSelection("/payments", map[string]interface{}{
+		"trace_exporters": []string{"compliance"},
+	})
+	refunds := endpointWithSelection("/refunds", map[string]interface{}{
+		"trace_exporters": []string{"compliance", "compliance"},
+	})
+	defaults := endpointWithSelection("/defaults", map[string]interface{}{
+		"trace_exporters": []string{"main"},
+	})
+	unknown := endpointWithSelection("/unknown", map[string]interface{}{
+		"trace_exporters": []string{"missing"},
+	})
+	plain := endpointWithSelection("/plain", nil)
+
+	paymentsState := stateCfg.EndpointOTEL(payments)
+	if paymentsState == global {
+		t.Errorf("expected a state for the selected exporters")
+		return
+	}
+	if stateCfg.EndpointOTEL(payments) != paymentsState {
+		t.Errorf("the state must be cached for the endpoint")
+	}
+	if stateCfg.EndpointOTEL(refunds) != paymentsState {
+		t.Errorf("the state must be shared by the endpoints with the same exporters")
+	}
+	for _, e := range []*luraconfig.EndpointConfig{defaults, unknown, plain} {
+		if stateCfg.EndpointOTEL(e) != global {
+			t.Errorf("expected the global state for %s", e.Endpoint)
+		}
+	}
+
+	if err := ValidateExporterSelection(stateCfg, payments.ExtraConfig); err != nil {
+		t.Errorf("unexpected error validating the selection: %s", err.Error())
+	}
+	if err := ValidateExporterSelection(stateCfg, unknown.ExtraConfig); err == nil {
+		t.Errorf("expected an error for the unknown exporter")
+	}
+
+	backend := &luraconfig.Backend{ExtraConfig: payments.ExtraConfig}
+	if stateCfg.BackendOTEL(backend) != paymentsState {
+		t.Errorf("the backends must share the state with the endpoints with the same exporters")
+	}
+
+	_, span := paymentsState.Tracer().Start(context.Background(), "payment")
+	span.End()
+	_, span = global.Tracer().Start(context.Background(), "other")
+	span.End()
+
+	if spans := compliance.exp.GetSpans(); len(spans) != 1 || spans[0].Name != "payment" {
+		t.Errorf("expected only the payment span in the compliance exporter, got %d spans", len(spans))
+	}
+	if spans := main.exp.GetSpans(); len(spans) != 1 || spans[0].Name != "other" {
+		t.Errorf("expected only the other span in the main exporter, got %d spans", len(spans))
+	}
+}
+
+func TestStateConfig_exporterSelectionSharesSampling(t *testing.T) {
+	sync := &config.BatchOpts{Synchronous: true}
+	main := &selectableSpanExporter{newTestSpanExporter(sync), true}
+	compliance := &selectableSpanExporter{newTestSpanExporter(sync), false}
+	te := map[string]exporter.SpanExporter{
+		"main":       main,
+		"compliance": compliance,
+	}
+	tailOpts := &config.TailSamplingOpts{Errors: true}
+
+	global, err := NewWithVersion("test", &OTELStateConfig{
+		TraceProviders:  []string{"main"},
+		TraceSampleRate: 1.0,
+		TailSampling:    tailOpts,
+	}, "v0.0.0", nil, te)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+	SetGlobalState(global)
+	defer SetGlobalState(nil)
+
+	stateCfg := NewConfigWithExporters(&config.ConfigData{ServiceName: "test", TailSampling: tailOpts},
+		nil, te)
+	defer stateCfg.Shutdown(context.Background())
+
+	payments := endpointWithSelection("/payments", map[string]interface{}{
+		"trace_exporters": []string{"compliance"},
+	})
+	paymentsState, ok := stateCfg.EndpointOTEL(payments).(*OTELState)
+	if !ok || paymentsState == global {
+		t.Errorf("expected a state for the selected exporters")
+		return
+	}
+	if paymentsState.sampler != global.sampler {
+		t.Errorf("expected the sampler of the global state to be shared")
+	}
+	if paymentsState.tailSampler != nil {
+		t.Errorf("expected the tail sampler of the global state to be shared")
+	}
+
+	// the error in the span of the selected state keeps the whole
+	// trace, with each span reported to its own exporters
+	ctx, root := global.Tracer().Start(context.Background(), "root")
+	_, child := paymentsState.Tracer().Start(ctx, "payment")
+	child.SetStatus(codes.Error, "")
+	child.End()
+	if n := len(compliance.exp.GetSpans()); n != 0 {
+		t.Errorf("expected the span to wait for the decision, got %d spans", n)
+	}
+	root.End()
+
+	if spans := compliance.exp.GetSpans(); len(spans) != 1 || spans[0].Name != "payment" {
+		t.Errorf("expected the payment span in the compliance exporter, got %d spans", len(spans))
+	}
+	if spans := main.exp.GetSpans(); len(spans) != 1 || spans[0].Name != "root" {
+		t.Errorf("expected the root span in the main exporter, got %d spans", len(spans))
+	}
+}
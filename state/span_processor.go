@@ -0,0 +1,121 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/krakend/krakend-otel/config"
+	"github.com/krakend/krakend-otel/exporter"
+)
+
+// spanProcessor creates the processor that feeds the spans to the
+// exporter, using its batch options.
+func spanProcessor(se exporter.SpanExporter) (sdktrace.SpanProcessor, error) {
+	exp := se.SpanExporter()
+	b := se.BatchOpts()
+	if b == nil {
+		return sdktrace.NewBatchSpanProcessor(exp), nil
+	}
+	if b.Synchronous {
+		return sdktrace.NewSimpleSpanProcessor(exp), nil
+	}
+	if err := b.Validate(); err != nil {
+		return nil, err
+	}
+
+	opts := make([]sdktrace.BatchSpanProcessorOption, 0, 4)
+	if b.MaxQueueSize > 0 {
+		opts = append(opts, sdktrace.WithMaxQueueSize(b.MaxQueueSize))
+	}
+	if b.MaxExportBatchSize > 0 {
+		opts = append(opts, sdktrace.WithMaxExportBatchSize(b.MaxExportBatchSize))
+	}
+	batchTimeout, err := config.ParseDuration(b.BatchTimeout, 0)
+	if err != nil {
+		return nil, fmt.Errorf("bad batch_timeout: %s", err.Error())
+	}
+	if batchTimeout > 0 {
+		opts = append(opts, sdktrace.WithBatchTimeout(batchTimeout))
+	}
+	exportTimeout, err := config.ParseDuration(b.ExportTimeout, 0)
+	if err != nil {
+		return nil, fmt.Errorf("bad export_timeout: %s", err.Error())
+	}
+	if exportTimeout > 0 {
+		opts = append(opts, sdktrace.WithExportTimeout(exportTimeout))
+	}
+	return sdktrace.NewBatchSpanProcessor(exp, opts...), nil
+}
+
+// spanRouter passes the spans of a state to its processors, except
+// the ones routed to the processors of another state (see [RouteSpan]).
+type spanRouter struct {
+	next []sdktrace.SpanProcessor
+
+	// routes has the processors for each routed span,
+	// that are removed when the span ends
+	routes sync.Map
+}
+
+func (r *spanRouter) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	for _, p := range r.next {
+		p.OnStart(parent, s)
+	}
+}
+
+func (r *spanRouter) OnEnd(s sdktrace.ReadOnlySpan) {
+	next := r.next
+	if v, ok := r.routes.LoadAndDelete(s.SpanContext().SpanID()); ok {
+		next, _ = v.([]sdktrace.SpanProcessor)
+	}
+	for _, p := range next {
+		p.OnEnd(s)
+	}
+}
+
+func (r *spanRouter) Shutdown(ctx context.Context) error {
+	var errs []error
+	for _, p := range r.next {
+		errs = append(errs, p.Shutdown(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+func (r *spanRouter) ForceFlush(ctx context.Context) error {
+	var errs []error
+	for _, p := range r.next {
+		errs = append(errs, p.ForceFlush(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// RouteSpan sends a span started with the tracer of the from state to
+// the exporters of the to state when it ends, like the server span of
+// a request whose endpoint selects its own exporters. It must be called
+// before ending the span, and does nothing when the span is not being
+// recorded or the states are the same (or not [*OTELState] instances).
+//
+// The span is dropped when the to state has no traces exporters.
+func RouteSpan(from, to OTEL, span trace.Span) {
+	f, ok := from.(*OTELState)
+	if !ok || f == nil || f.spanRouter == nil {
+		return
+	}
+	t, ok := to.(*OTELState)
+	if !ok || t == nil || t == f {
+		return
+	}
+	if span == nil || !span.IsRecording() {
+		return
+	}
+	var next []sdktrace.SpanProcessor
+	if t.spanRouter != nil {
+		next = t.spanRouter.next
+	}
+	f.spanRouter.routes.Store(span.SpanContext().SpanID(), next)
+}
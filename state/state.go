@@ -9,16 +9,19 @@ import (
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/log"
+	nooplog "go.opentelemetry.io/otel/log/noop"
 	"go.opentelemetry.io/otel/metric"
 	noopmetric "go.opentelemetry.io/otel/metric/noop"
 	"go.opentelemetry.io/otel/propagation"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
 	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
-	sdkresource "go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	"go.opentelemetry.io/otel/semconv/v1.21.0"
 	"go.opentelemetry.io/otel/trace"
 	nooptrace "go.opentelemetry.io/otel/trace/noop"
 
+	"github.com/krakend/krakend-otel/config"
 	"github.com/krakend/krakend-otel/exporter"
 )
 
@@ -37,27 +40,71 @@ type OTEL interface {
 	TracerProvider() trace.TracerProvider
 }
 
+// LogsOTEL is implemented by the [OTEL] instances that can
+// also emit logs.
+type LogsOTEL interface {
+	Logger() log.Logger
+	LoggerProvider() log.LoggerProvider
+}
+
+var _ LogsOTEL = (*OTELState)(nil)
+
+// Logger returns the logger of the instance, or nil if the
+// instance does not implement [LogsOTEL].
+func Logger(s OTEL) log.Logger {
+	if ls, ok := s.(LogsOTEL); ok {
+		return ls.Logger()
+	}
+	return nil
+}
+
 // GetterFn defines a function that will return an [OTEL] instance.
 type GetterFn func() OTEL
 
 type OTELStateConfig struct {
 	MetricProviders       []string `json:"metric_providers"`
 	TraceProviders        []string `json:"trace_providers"`
+	LogProviders          []string `json:"log_providers"`
 	MetricReportingPeriod int      `json:"metric_reporting_period"`
 	TraceSampleRate       float64  `json:"trace_sample_rate"`
+
+	Sampler        *config.SamplerOpts        `json:"sampler"`
+	RemoteSampling *config.RemoteSamplingOpts `json:"remote_sampling"`
+	Resource       *config.ResourceOpts       `json:"resource"`
+	TailSampling   *config.TailSamplingOpts   `json:"tail_sampling"`
+	Propagators    []string                   `json:"propagators"`
+
+	BaggageAttributes *config.BaggageAttributesOpts `json:"baggage_attributes"`
+
+	// SampleRates has the rates of the endpoints and backends, that
+	// are shared by all the states created from the same [StateConfig].
+	SampleRates *SampleRates `json:"-"`
+
+	// base is the state that shares its sampler and tail sampler,
+	// instead of creating new ones (with their own remote sampling
+	// poller and tail sampling buffer).
+	base *OTELState
 }
 
 // OTELState is the basic implementation of an [OTEL] intstance.
 type OTELState struct {
 	meterProvider  metric.MeterProvider
 	tracerProvider trace.TracerProvider
+	loggerProvider log.LoggerProvider
 
 	// we need not the interface, but the actual implementation
 	// to be able to call shutown:
 	sdkMeterProvider  *sdkmetric.MeterProvider
 	sdkTracerProvider *sdktrace.TracerProvider
+	sdkLoggerProvider *sdklog.LoggerProvider
 	tracer            trace.Tracer
 	meter             metric.Meter
+	logger            log.Logger
+	propagator        propagation.TextMapPropagator
+	remoteSampler     *remoteSampler
+	sampler           sdktrace.Sampler
+	tailSampler       *tailSampler
+	spanRouter        *spanRouter
 }
 
 // NewWithVersion create a new OTELState with a version for
@@ -72,6 +119,16 @@ func NewWithVersion(serviceName string, cfg *OTELStateConfig, version string,
 // the KrakenD service, with the provided metrics and traces exporters
 func NewWithVersionAndEnv(serviceName string, cfg *OTELStateConfig, version string,
 	env string, me map[string]exporter.MetricReader, te map[string]exporter.SpanExporter,
+) (*OTELState, error) {
+	return NewWithLogs(serviceName, cfg, version, env, me, te, nil)
+}
+
+// NewWithLogs create a new OTELState with a version and environment
+// for the KrakenD service, with the provided metrics, traces and
+// logs exporters
+func NewWithLogs(serviceName string, cfg *OTELStateConfig, version string,
+	env string, me map[string]exporter.MetricReader, te map[string]exporter.SpanExporter,
+	le map[string]exporter.LogExporter,
 ) (*OTELState, error) {
 	sdkAttrs := []attribute.KeyValue{
 		semconv.ServiceName(serviceName),
@@ -80,7 +137,15 @@ func NewWithVersionAndEnv(serviceName string, cfg *OTELStateConfig, version stri
 	if env != "" {
 		sdkAttrs = append(sdkAttrs, semconv.DeploymentEnvironment(env))
 	}
-	res := sdkresource.NewWithAttributes(semconv.SchemaURL, sdkAttrs...)
+	res, err := newResource(context.Background(), sdkAttrs, cfg.Resource)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create the resource: %s", err.Error())
+	}
+
+	propagator, err := NewPropagator(cfg.Propagators)
+	if err != nil {
+		return nil, err
+	}
 
 	reportingPeriod := time.Duration(cfg.MetricReportingPeriod) * time.Second
 	metricOpts := make([]sdkmetric.Option, 0, len(cfg.MetricProviders)+2)
@@ -104,36 +169,98 @@ func NewWithVersionAndEnv(serviceName string, cfg *OTELStateConfig, version stri
 
 	// Configure the tracing part
 	traceOpts := make([]sdktrace.TracerProviderOption, 0, len(cfg.TraceProviders)+2)
+	spanProcessors := make([]sdktrace.SpanProcessor, 0, len(cfg.TraceProviders))
 	for idx, prov := range cfg.TraceProviders {
 		pt, ok := te[prov]
 		if !ok {
 			return nil, fmt.Errorf("not found exporter %s for provider %d for tracing", prov, idx)
 		}
-		traceOpts = append(traceOpts, sdktrace.WithBatcher(pt.SpanExporter()))
+		sp, err := spanProcessor(pt)
+		if err != nil {
+			return nil, fmt.Errorf("bad span processor for exporter %s: %s", prov, err.Error())
+		}
+		spanProcessors = append(spanProcessors, sp)
+	}
+	var tail *tailSampler
+	if cfg.TailSampling != nil && len(spanProcessors) > 0 {
+		if cfg.base != nil && cfg.base.tailSampler != nil {
+			spanProcessors = []sdktrace.SpanProcessor{
+				&tailSamplerInput{ts: cfg.base.tailSampler, next: spanProcessors},
+			}
+		} else {
+			ts, err := newTailSampler(cfg.TailSampling, meter, spanProcessors)
+			if err != nil {
+				return nil, fmt.Errorf("bad tail sampling: %s", err.Error())
+			}
+			tail = ts
+			spanProcessors = []sdktrace.SpanProcessor{ts}
+		}
+	}
+	if ba := NewBaggageAttributes(cfg.BaggageAttributes); ba != nil && len(spanProcessors) > 0 {
+		// it must run before the exporters' processors
+		spanProcessors = append([]sdktrace.SpanProcessor{&baggageSpanProcessor{attrs: ba}}, spanProcessors...)
+	}
+	var router *spanRouter
+	if len(spanProcessors) > 0 {
+		router = &spanRouter{next: spanProcessors}
+		traceOpts = append(traceOpts, sdktrace.WithSpanProcessor(router))
 	}
 
 	var tracerProvider trace.TracerProvider = nooptrace.NewTracerProvider()
 	var sdkTracerProvider *sdktrace.TracerProvider
+	var remote *remoteSampler
+	var sampler sdktrace.Sampler
 	if len(traceOpts) > 0 {
-		samplerOpt := sdktrace.WithSampler(sdktrace.AlwaysSample())
-		if cfg.TraceSampleRate > 0.0 && cfg.TraceSampleRate < 1.0 {
-			samplerOpt = sdktrace.WithSampler(sdktrace.ParentBased(
-				sdktrace.TraceIDRatioBased(cfg.TraceSampleRate)))
+		if cfg.base != nil && cfg.base.sampler != nil {
+			sampler = cfg.base.sampler
+		} else {
+			if cfg.RemoteSampling != nil {
+				remote = newRemoteSampler(serviceName, cfg.RemoteSampling)
+				remote.start()
+			}
+			sampler = newSampler(cfg.TraceSampleRate, cfg.Sampler, cfg.SampleRates, remote)
 		}
-		traceOpts = append(traceOpts, samplerOpt)
+		traceOpts = append(traceOpts, sdktrace.WithSampler(sampler))
 		traceOpts = append(traceOpts, sdktrace.WithResource(res))
 		sdkTracerProvider = sdktrace.NewTracerProvider(traceOpts...)
 		tracerProvider = sdkTracerProvider
 	}
 	tracer := tracerProvider.Tracer(providerName)
 
+	// Configure the logs part
+	logOpts := make([]sdklog.LoggerProviderOption, 0, len(cfg.LogProviders)+1)
+	for idx, prov := range cfg.LogProviders {
+		pl, ok := le[prov]
+		if !ok {
+			return nil, fmt.Errorf("not found exporter %s for provider %d for logs", prov, idx)
+		}
+		logOpts = append(logOpts, sdklog.WithProcessor(sdklog.NewBatchProcessor(pl.LogExporter())))
+	}
+
+	var loggerProvider log.LoggerProvider = nooplog.NewLoggerProvider()
+	var sdkLoggerProvider *sdklog.LoggerProvider
+	if len(logOpts) > 0 {
+		logOpts = append(logOpts, sdklog.WithResource(res))
+		sdkLoggerProvider = sdklog.NewLoggerProvider(logOpts...)
+		loggerProvider = sdkLoggerProvider
+	}
+	logger := loggerProvider.Logger(providerName)
+
 	return &OTELState{
 		meterProvider:     meterProvider,
 		tracerProvider:    tracerProvider,
+		loggerProvider:    loggerProvider,
 		sdkMeterProvider:  sdkMeterProvider,
 		sdkTracerProvider: sdkTracerProvider,
+		sdkLoggerProvider: sdkLoggerProvider,
 		tracer:            tracer,
 		meter:             meter,
+		logger:            logger,
+		propagator:        propagator,
+		remoteSampler:     remote,
+		sampler:           sampler,
+		tailSampler:       tail,
+		spanRouter:        router,
 	}, nil
 }
 
@@ -153,6 +280,21 @@ func (s *OTELState) Meter() metric.Meter {
 	return s.meter
 }
 
+// Logger returns a logger to emit log records.
+func (s *OTELState) Logger() log.Logger {
+	if s == nil {
+		return nil
+	}
+	return s.logger
+}
+
+func (s *OTELState) LoggerProvider() log.LoggerProvider {
+	if s == nil {
+		return nil
+	}
+	return s.loggerProvider
+}
+
 func (s *OTELState) MeterProvider() metric.MeterProvider {
 	if s == nil {
 		return nil
@@ -167,24 +309,49 @@ func (s *OTELState) TracerProvider() trace.TracerProvider {
 	return s.tracerProvider
 }
 
-// Propagator returns the configured propagator to use.
+// Propagator returns the configured propagator to use, or
+// the global one when none has been configured.
 func (s *OTELState) Propagator() propagation.TextMapPropagator {
 	if s == nil {
 		return nil
 	}
+	if s.propagator != nil {
+		return s.propagator
+	}
 	return otel.GetTextMapPropagator()
 }
 
+// ForceFlush exports the pending traces, metrics and / or logs
+// without shutting down the providers.
+func (s *OTELState) ForceFlush(ctx context.Context) {
+	if s == nil {
+		return
+	}
+	if s.sdkTracerProvider != nil {
+		s.sdkTracerProvider.ForceFlush(ctx)
+	}
+	if s.sdkMeterProvider != nil {
+		s.sdkMeterProvider.ForceFlush(ctx)
+	}
+	if s.sdkLoggerProvider != nil {
+		s.sdkLoggerProvider.ForceFlush(ctx)
+	}
+}
+
 // Shutdown performs the clean shutdown to be able to
-// flush pending traces and / or metrics.
+// flush pending traces, metrics and / or logs.
 func (s *OTELState) Shutdown(ctx context.Context) {
 	if s == nil {
 		return
 	}
+	s.remoteSampler.stop()
 	if s.sdkTracerProvider != nil {
 		s.sdkTracerProvider.Shutdown(ctx)
 	}
 	if s.sdkMeterProvider != nil {
 		s.sdkMeterProvider.Shutdown(ctx)
 	}
+	if s.sdkLoggerProvider != nil {
+		s.sdkLoggerProvider.Shutdown(ctx)
+	}
 }
@@ -0,0 +1,176 @@
+package state
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	sdktracetest "go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/krakend/krakend-otel/config"
+	"github.com/krakend/krakend-otel/exporter"
+)
+
+type testSpanExporter struct {
+	exp   *sdktracetest.InMemoryExporter
+	batch *config.BatchOpts
+}
+
+func newTestSpanExporter(batch *config.BatchOpts) *testSpanExporter {
+	return &testSpanExporter{
+		exp:   sdktracetest.NewInMemoryExporter(),
+		batch: batch,
+	}
+}
+
+func (e *testSpanExporter) SpanExporter() sdktrace.SpanExporter {
+	return e.exp
+}
+
+func (*testSpanExporter) TraceDefaultReporting() bool {
+	return true
+}
+
+func (e *testSpanExporter) BatchOpts() *config.BatchOpts {
+	return e.batch
+}
+
+func newTestTracesState(t *testing.T, te map[string]exporter.SpanExporter) (*OTELState, error) {
+	t.Helper()
+	providers := make([]string, 0, len(te))
+	for k := range te {
+		providers = append(providers, k)
+	}
+	cfg := &OTELStateConfig{
+		TraceProviders:  providers,
+		TraceSampleRate: 1.0,
+	}
+	return NewWithVersion("test", cfg, "v0.0.0", nil, te)
+}
+
+func TestBatchOpts(t *testing.T) {
+	testCases := []struct {
+		name            string
+		batch           *config.BatchOpts
+		exportedOnEnd   int
+		exportedOnClose int
+	}{
+		{
+			name:            "defaults",
+			batch:           nil,
+			exportedOnEnd:   0,
+			exportedOnClose: 1,
+		},
+		{
+			name: "batched",
+			batch: &config.BatchOpts{
+				MaxQueueSize:       10,
+				MaxExportBatchSize: 5,
+				BatchTimeout:       "1h",
+				ExportTimeout:      "1s",
+			},
+			exportedOnEnd:   0,
+			exportedOnClose: 1,
+		},
+		{
+			name:            "synchronous",
+			batch:           &config.BatchOpts{Synchronous: true},
+			exportedOnEnd:   1,
+			exportedOnClose: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			se := newTestSpanExporter(tc.batch)
+			s, err := newTestTracesState(t, map[string]exporter.SpanExporter{"test": se})
+			if err != nil {
+				t.Errorf("unexpected error: %s", err.Error())
+				return
+			}
+			_, span := s.Tracer().Start(context.Background(), "test-span")
+			span.End()
+			if got := len(se.exp.GetSpans()); got != tc.exportedOnEnd {
+				t.Errorf("exported spans on end, want: %d, got: %d", tc.exportedOnEnd, got)
+			}
+			// we flush instead of shutting down, because the in memory exporter
+			// removes the spans on shutdown.
+			s.sdkTracerProvider.ForceFlush(context.Background())
+			if got := len(se.exp.GetSpans()); got != tc.exportedOnClose {
+				t.Errorf("exported spans on close, want: %d, got: %d", tc.exportedOnClose, got)
+			}
+			s.Shutdown(context.Background())
+		})
+	}
+}
+
+func TestBatchOpts_invalid(t *testing.T) {
+	se := newTestSpanExporter(&config.BatchOpts{
+		MaxQueueSize:       1,
+		MaxExportBatchSize: 10,
+	})
+	_, err := newTestTracesState(t, map[string]exporter.SpanExporter{"test": se})
+	if err == nil {
+		t.Errorf("expected error for batch size greater than the queue size")
+	}
+}
+
+type testLogExporter struct {
+	mu      sync.Mutex
+	records []sdklog.Record
+}
+
+func (e *testLogExporter) LogExporter() sdklog.Exporter {
+	return e
+}
+
+func (*testLogExporter) LogDefaultReporting() bool {
+	return true
+}
+
+func (e *testLogExporter) Export(_ context.Context, records []sdklog.Record) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, r := range records {
+		e.records = append(e.records, r.Clone())
+	}
+	return nil
+}
+
+func (*testLogExporter) Shutdown(context.Context) error   { return nil }
+func (*testLogExporter) ForceFlush(context.Context) error { return nil }
+
+func TestNewWithLogs(t *testing.T) {
+	le := &testLogExporter{}
+	cfg := &OTELStateConfig{LogProviders: []string{"test"}}
+	s, err := NewWithLogs("test", cfg, "v0.0.0", "", nil, nil,
+		map[string]exporter.LogExporter{"test": le})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+		return
+	}
+
+	var rec log.Record
+	rec.SetBody(log.StringValue("test-record"))
+	Logger(s).Emit(context.Background(), rec)
+	s.Shutdown(context.Background())
+
+	le.mu.Lock()
+	defer le.mu.Unlock()
+	if len(le.records) != 1 {
+		t.Errorf("expected 1 exported record, got %d", len(le.records))
+		return
+	}
+	if got := le.records[0].Body().AsString(); got != "test-record" {
+		t.Errorf("unexpected record body: %q", got)
+	}
+
+	_, err = NewWithLogs("test", &OTELStateConfig{LogProviders: []string{"missing"}},
+		"v0.0.0", "", nil, nil, nil)
+	if err == nil {
+		t.Errorf("expected error for a missing logs exporter")
+	}
+}
@@ -0,0 +1,328 @@
+package state
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/krakend/krakend-otel/config"
+)
+
+const (
+	defaultTailMaxTraces        = 10000
+	defaultTailMaxSpansPerTrace = 1000
+	defaultTailDecisionWait     = 30 * time.Second
+	tailDecisionsPerTrace       = 4
+
+	tailEvictionsMetric = "krakend.otel.tail_sampling.evictions"
+)
+
+var tailEvictionReasonKey = attribute.Key("reason")
+
+// tailSampler is a span processor that keeps the ended spans of each
+// trace in memory until its local root span ends, and then decides if
+// the whole trace is passed to the next processors or dropped.
+//
+// It can be shared by other states with a [tailSamplerInput], so the
+// spans of a trace that are reported to different exporters are kept
+// or dropped together, each one passed to the processors of its state.
+type tailSampler struct {
+	next []sdktrace.SpanProcessor
+
+	errors    bool
+	latency   time.Duration
+	routes    map[string]bool
+	ratio     sdktrace.Sampler
+	maxTraces int
+	maxSpans  int
+	wait      time.Duration
+	evictions metric.Int64Counter
+	now       func() time.Time
+
+	mu      sync.Mutex
+	pending map[trace.TraceID]*pendingTrace
+	order   *list.List
+	// decided keeps the decision for the last traces, for the spans
+	// that end after their local root (like the ones from async agents)
+	decided      map[trace.TraceID]bool
+	decidedOrder []trace.TraceID
+	decidedIdx   int
+}
+
+type pendingTrace struct {
+	id      trace.TraceID
+	spans   []tailSpan
+	created time.Time
+	elem    *list.Element
+	keep    bool
+}
+
+// tailSpan is an ended span with the processors to pass it to.
+type tailSpan struct {
+	span sdktrace.ReadOnlySpan
+	next []sdktrace.SpanProcessor
+}
+
+func newTailSampler(opts *config.TailSamplingOpts, meter metric.Meter,
+	next []sdktrace.SpanProcessor,
+) (*tailSampler, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	latency, _ := config.ParseDuration(opts.LatencyThreshold, 0)
+	wait, _ := config.ParseDuration(opts.DecisionWait, defaultTailDecisionWait)
+	ts := &tailSampler{
+		next:      next,
+		errors:    opts.Errors,
+		latency:   latency,
+		routes:    make(map[string]bool, len(opts.Routes)),
+		ratio:     sdktrace.TraceIDRatioBased(opts.SampleRate),
+		maxTraces: opts.MaxTraces,
+		maxSpans:  opts.MaxSpansPerTrace,
+		wait:      wait,
+		now:       time.Now,
+		pending:   map[trace.TraceID]*pendingTrace{},
+		order:     list.New(),
+	}
+	for _, r := range opts.Routes {
+		ts.routes[config.NormalizeURLPattern(r)] = true
+	}
+	if ts.maxTraces == 0 {
+		ts.maxTraces = defaultTailMaxTraces
+	}
+	if ts.maxSpans == 0 {
+		ts.maxSpans = defaultTailMaxSpansPerTrace
+	}
+	// the decisions are cheaper to keep than the pending spans, so we
+	// can remember them for more traces than the ones we can hold
+	ts.decided = make(map[trace.TraceID]bool, tailDecisionsPerTrace*ts.maxTraces)
+	ts.decidedOrder = make([]trace.TraceID, tailDecisionsPerTrace*ts.maxTraces)
+
+	var err error
+	ts.evictions, err = meter.Int64Counter(tailEvictionsMetric,
+		metric.WithDescription("traces and spans dropped by the tail sampling because of its limits"))
+	if err != nil {
+		return nil, fmt.Errorf("cannot create the tail sampling metric: %s", err.Error())
+	}
+	return ts, nil
+}
+
+func (ts *tailSampler) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	ts.onStart(parent, s, ts.next)
+}
+
+func (ts *tailSampler) onStart(parent context.Context, s sdktrace.ReadWriteSpan,
+	next []sdktrace.SpanProcessor,
+) {
+	if (!s.Parent().IsValid() || s.Parent().IsRemote()) && s.SpanContext().IsSampled() {
+		if _, forced := ForceSampling(parent); forced {
+			ts.keepForced(s.SpanContext().TraceID())
+		}
+	}
+	for _, p := range next {
+		p.OnStart(parent, s)
+	}
+}
+
+// keepForced decides to keep a trace which sampling has been forced
+// (see [ContextWithForceSampling]) when its local root starts, so its
+// spans are forwarded as they end, whatever the rules.
+func (ts *tailSampler) keepForced(id trace.TraceID) {
+	ts.mu.Lock()
+	pt, ok := ts.pending[id]
+	if ok {
+		ts.remove(pt)
+	}
+	ts.setDecision(id, true)
+	ts.mu.Unlock()
+	if ok {
+		forward(pt.spans...)
+	}
+}
+
+func (ts *tailSampler) OnEnd(s sdktrace.ReadOnlySpan) {
+	ts.onEnd(s, ts.next)
+}
+
+func (ts *tailSampler) onEnd(s sdktrace.ReadOnlySpan, next []sdktrace.SpanProcessor) {
+	sc := s.SpanContext()
+	if !sc.IsSampled() {
+		return
+	}
+	id := sc.TraceID()
+
+	ts.mu.Lock()
+	if keep, ok := ts.decided[id]; ok {
+		ts.mu.Unlock()
+		if keep {
+			forward(tailSpan{span: s, next: next})
+		}
+		return
+	}
+
+	now := ts.now()
+	ts.evictExpired(now)
+	pt, ok := ts.pending[id]
+	if !ok {
+		if len(ts.pending) >= ts.maxTraces {
+			ts.evict(ts.order.Front().Value.(*pendingTrace), "max_traces")
+		}
+		pt = &pendingTrace{id: id, created: now}
+		pt.elem = ts.order.PushBack(pt)
+		ts.pending[id] = pt
+	}
+	if len(pt.spans) < ts.maxSpans {
+		pt.spans = append(pt.spans, tailSpan{span: s, next: next})
+	} else {
+		ts.record("max_spans")
+	}
+	pt.keep = pt.keep || ts.matches(s)
+
+	if s.Parent().IsValid() && !s.Parent().IsRemote() {
+		ts.mu.Unlock()
+		return
+	}
+
+	// the local root has ended: time to decide
+	keep := pt.keep || (ts.latency > 0 && s.EndTime().Sub(s.StartTime()) >= ts.latency) ||
+		ts.ratio.ShouldSample(sdktrace.SamplingParameters{TraceID: id}).Decision == sdktrace.RecordAndSample
+	ts.remove(pt)
+	ts.setDecision(id, keep)
+	ts.mu.Unlock()
+
+	if keep {
+		forward(pt.spans...)
+	}
+}
+
+// matches checks the rules that can be evaluated for any
+// span of the trace.
+func (ts *tailSampler) matches(s sdktrace.ReadOnlySpan) bool {
+	if ts.errors && s.Status().Code == codes.Error {
+		return true
+	}
+	if len(ts.routes) == 0 {
+		return false
+	}
+	for _, kv := range s.Attributes() {
+		if kv.Key == semconv.HTTPRouteKey && ts.routes[kv.Value.AsString()] {
+			return true
+		}
+	}
+	return false
+}
+
+func forward(spans ...tailSpan) {
+	for _, s := range spans {
+		for _, p := range s.next {
+			p.OnEnd(s.span)
+		}
+	}
+}
+
+// evictExpired drops the traces that have been waiting for
+// a decision longer than the configured time.
+func (ts *tailSampler) evictExpired(now time.Time) {
+	for e := ts.order.Front(); e != nil; e = ts.order.Front() {
+		pt := e.Value.(*pendingTrace)
+		if now.Sub(pt.created) < ts.wait {
+			return
+		}
+		ts.evict(pt, "timeout")
+	}
+}
+
+func (ts *tailSampler) evict(pt *pendingTrace, reason string) {
+	ts.remove(pt)
+	ts.setDecision(pt.id, false)
+	ts.record(reason)
+}
+
+func (ts *tailSampler) record(reason string) {
+	ts.evictions.Add(context.Background(), 1,
+		metric.WithAttributes(tailEvictionReasonKey.String(reason)))
+}
+
+func (ts *tailSampler) remove(pt *pendingTrace) {
+	ts.order.Remove(pt.elem)
+	delete(ts.pending, pt.id)
+}
+
+func (ts *tailSampler) setDecision(id trace.TraceID, keep bool) {
+	old := ts.decidedOrder[ts.decidedIdx]
+	if old.IsValid() {
+		delete(ts.decided, old)
+	}
+	ts.decidedOrder[ts.decidedIdx] = id
+	ts.decidedIdx = (ts.decidedIdx + 1) % len(ts.decidedOrder)
+	ts.decided[id] = keep
+}
+
+// Shutdown drops the traces that are still waiting for a decision,
+// and shuts down the next processors.
+func (ts *tailSampler) Shutdown(ctx context.Context) error {
+	ts.mu.Lock()
+	ts.pending = map[trace.TraceID]*pendingTrace{}
+	ts.order.Init()
+	ts.mu.Unlock()
+
+	var errs []error
+	for _, p := range ts.next {
+		errs = append(errs, p.Shutdown(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// ForceFlush flushes the next processors: the traces that are
+// waiting for a decision are kept.
+func (ts *tailSampler) ForceFlush(ctx context.Context) error {
+	var errs []error
+	for _, p := range ts.next {
+		errs = append(errs, p.ForceFlush(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// tailSamplerInput feeds the spans of a state to a [tailSampler]
+// owned by another state, that passes them to the next processors
+// of this one when the trace is kept.
+type tailSamplerInput struct {
+	ts   *tailSampler
+	next []sdktrace.SpanProcessor
+}
+
+func (in *tailSamplerInput) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	in.ts.onStart(parent, s, in.next)
+}
+
+func (in *tailSamplerInput) OnEnd(s sdktrace.ReadOnlySpan) {
+	in.ts.onEnd(s, in.next)
+}
+
+// Shutdown shuts down the next processors, as the tail
+// sampler is shut down by its owner.
+func (in *tailSamplerInput) Shutdown(ctx context.Context) error {
+	var errs []error
+	for _, p := range in.next {
+		errs = append(errs, p.Shutdown(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+func (in *tailSamplerInput) ForceFlush(ctx context.Context) error {
+	var errs []error
+	for _, p := range in.next {
+		errs = append(errs, p.ForceFlush(ctx))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,198 @@
+package state
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	sdktracetest "go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/krakend/krakend-otel/config"
+)
+
+type tailSamplingTest struct {
+	tracer   trace.Tracer
+	recorder *sdktracetest.SpanRecorder
+	reader   *sdkmetric.ManualReader
+	sampler  *tailSampler
+}
+
+func newTailSamplingTest(t *testing.T, opts *config.TailSamplingOpts) *tailSamplingTest {
+	t.Helper()
+	recorder := sdktracetest.NewSpanRecorder()
+	reader := sdkmetric.NewManualReader()
+	meter := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("test")
+	ts, err := newTailSampler(opts, meter, []sdktrace.SpanProcessor{recorder})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(ts))
+	return &tailSamplingTest{
+		tracer:   tp.Tracer("test"),
+		recorder: recorder,
+		reader:   reader,
+		sampler:  ts,
+	}
+}
+
+// trace creates a root span with a child, and returns the
+// number of spans exported.
+func (tt *tailSamplingTest) trace(route string, childStatus codes.Code, rootDuration time.Duration) int {
+	before := len(tt.recorder.Ended())
+	start := time.Now()
+	ctx, root := tt.tracer.Start(context.Background(), "root", trace.WithTimestamp(start))
+	_, child := tt.tracer.Start(ctx, "child")
+	if route != "" {
+		child.SetAttributes(semconv.HTTPRoute(route))
+	}
+	child.SetStatus(childStatus, "")
+	child.End()
+	root.End(trace.WithTimestamp(start.Add(rootDuration)))
+	return len(tt.recorder.Ended()) - before
+}
+
+func (tt *tailSamplingTest) evictions(t *testing.T) map[string]int64 {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := tt.reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	res := map[string]int64{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != tailEvictionsMetric {
+				continue
+			}
+			for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+				reason, _ := dp.Attributes.Value(tailEvictionReasonKey)
+				res[reason.AsString()] = dp.Value
+			}
+		}
+	}
+	return res
+}
+
+func TestTailSampling_rules(t *testing.T) {
+	tt := newTailSamplingTest(t, &config.TailSamplingOpts{
+		Errors:           true,
+		LatencyThreshold: "500ms",
+		Routes:           []string{"/payments/{{.Id}}"},
+	})
+	for _, tc := range []struct {
+		name     string
+		route    string
+		status   codes.Code
+		duration time.Duration
+		expected int
+	}{
+		{"dropped", "/users/:id", codes.Ok, time.Millisecond, 0},
+		{"error", "", codes.Error, time.Millisecond, 2},
+		{"latency", "", codes.Unset, time.Second, 2},
+		{"route", "/payments/:id", codes.Unset, time.Millisecond, 2},
+	} {
+		if n := tt.trace(tc.route, tc.status, tc.duration); n != tc.expected {
+			t.Errorf("%s: expected %d spans, got %d", tc.name, tc.expected, n)
+		}
+	}
+	if len(tt.sampler.pending) != 0 {
+		t.Errorf("unexpected pending traces: %d", len(tt.sampler.pending))
+	}
+}
+
+func TestTailSampling_sampleRate(t *testing.T) {
+	tt := newTailSamplingTest(t, &config.TailSamplingOpts{SampleRate: 1})
+	if n := tt.trace("", codes.Unset, time.Millisecond); n != 2 {
+		t.Errorf("expected the trace to be kept by the sample rate, got %d spans", n)
+	}
+}
+
+func TestTailSampling_lateSpans(t *testing.T) {
+	tt := newTailSamplingTest(t, &config.TailSamplingOpts{Errors: true})
+	ctx, root := tt.tracer.Start(context.Background(), "root")
+	_, late := tt.tracer.Start(ctx, "late")
+	root.SetStatus(codes.Error, "")
+	root.End()
+	late.End()
+	if n := len(tt.recorder.Ended()); n != 2 {
+		t.Errorf("expected the late span to follow the decision, got %d spans", n)
+	}
+}
+
+func TestTailSampling_forced(t *testing.T) {
+	tt := newTailSamplingTest(t, &config.TailSamplingOpts{Errors: true})
+	ctx := ContextWithForceSampling(context.Background(), "")
+	ctx, root := tt.tracer.Start(ctx, "root")
+	_, child := tt.tracer.Start(ctx, "child")
+	child.End()
+	root.End()
+	if n := len(tt.recorder.Ended()); n != 2 {
+		t.Errorf("expected the forced trace to be kept, got %d spans", n)
+	}
+	if len(tt.sampler.pending) != 0 {
+		t.Errorf("unexpected pending traces: %d", len(tt.sampler.pending))
+	}
+}
+
+func TestTailSampling_limits(t *testing.T) {
+	tt := newTailSamplingTest(t, &config.TailSamplingOpts{
+		Errors:           true,
+		MaxTraces:        2,
+		MaxSpansPerTrace: 2,
+		DecisionWait:     "1m",
+	})
+	now := time.Now()
+	tt.sampler.now = func() time.Time { return now }
+
+	var roots []trace.Span
+	for i := 0; i < 3; i++ {
+		ctx, root := tt.tracer.Start(context.Background(), "root")
+		for j := 0; j < 3; j++ {
+			_, child := tt.tracer.Start(ctx, "child")
+			child.End()
+		}
+		root.SetStatus(codes.Error, "")
+		roots = append(roots, root)
+	}
+	if len(tt.sampler.pending) != 2 {
+		t.Errorf("expected 2 pending traces, got %d", len(tt.sampler.pending))
+	}
+
+	now = now.Add(2 * time.Minute)
+	_, other := tt.tracer.Start(context.Background(), "other")
+	other.End()
+
+	for _, root := range roots {
+		root.End()
+	}
+	if n := len(tt.recorder.Ended()); n != 0 {
+		t.Errorf("expected the evicted traces to be dropped, got %d spans", n)
+	}
+
+	evictions := tt.evictions(t)
+	expected := map[string]int64{"max_traces": 1, "max_spans": 3, "timeout": 2}
+	for k, v := range expected {
+		if evictions[k] != v {
+			t.Errorf("evictions for %s: expected %d, got %d", k, v, evictions[k])
+		}
+	}
+}
+
+func TestTailSamplingOpts_validate(t *testing.T) {
+	for _, opts := range []*config.TailSamplingOpts{
+		{SampleRate: 2},
+		{MaxTraces: -1},
+		{LatencyThreshold: "slow"},
+		{DecisionWait: "10"},
+	} {
+		cfg := &config.ConfigData{TailSampling: opts}
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("expected error for %+v", opts)
+		}
+	}
+}